@@ -0,0 +1,442 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// serverReflectionServiceName 是 gRPC Server Reflection 自身的 service 名，发现时需要排除。
+const serverReflectionServiceName = "grpc.reflection.v1alpha.ServerReflection"
+
+// Filter 决定某个 fullMethodName（形如 /pkg.Service/Method）是否应该被 AutoRegister 暴露，
+// 与 LoadBalancer 一样是一个可替换的扩展点：内置 NewGlobFilter，调用方也可以自行实现。
+type Filter interface {
+	Allow(fullMethodName string) bool
+}
+
+// filterRule 是 globFilter 持有的单条规则；exclude 为 true 时表示排除。
+type filterRule struct {
+	pattern string
+	exclude bool
+}
+
+// globFilter 是 Filter 的默认实现，按 glob 规则匹配 fullMethodName。
+type globFilter struct {
+	rules []filterRule
+}
+
+// NewGlobFilter 基于一组规则构建 Filter：
+//   - "acme.demo.v1.*" 这类不含 "/" 的规则按 service 名匹配，对该 service 下的所有方法生效；
+//   - "/acme.demo.v1.*/*"、"!*/Admin*" 这类含 "/" 的规则与 Router 的路由匹配语义一致
+//     （按 "/" 分段，每段各自做 path.Match），"!" 前缀表示排除。
+//
+// 排除规则优先于包含规则。如果一条都没有配置包含规则，则默认放行所有未被排除的方法。
+func NewGlobFilter(rules ...string) Filter {
+	f := &globFilter{}
+
+	for _, r := range rules {
+		if pattern, ok := strings.CutPrefix(r, "!"); ok {
+			f.rules = append(f.rules, filterRule{pattern: pattern, exclude: true})
+			continue
+		}
+
+		f.rules = append(f.rules, filterRule{pattern: r})
+	}
+
+	return f
+}
+
+func (f *globFilter) Allow(fullMethodName string) bool {
+	hasInclude := false
+	included := false
+
+	for _, rule := range f.rules {
+		if !filterRuleMatches(rule.pattern, fullMethodName) {
+			continue
+		}
+
+		if rule.exclude {
+			return false
+		}
+
+		included = true
+	}
+
+	for _, rule := range f.rules {
+		if !rule.exclude {
+			hasInclude = true
+			break
+		}
+	}
+
+	if !hasInclude {
+		return true
+	}
+
+	return included
+}
+
+// filterRuleMatches 判断 pattern 是否匹配 fullMethodName：不含 "/" 的 pattern 按 service 名匹配，
+// 否则复用 Router 的 globMatch（按 "/" 分段）。
+func filterRuleMatches(pattern, fullMethodName string) bool {
+	if strings.Contains(pattern, "/") {
+		return globMatch(pattern, fullMethodName)
+	}
+
+	serviceName, _, err := splitFullMethod(fullMethodName)
+	if err != nil {
+		return false
+	}
+
+	ok, err := path.Match(pattern, serviceName)
+	return err == nil && ok
+}
+
+// autoRegisterOptions 汇总 AutoRegister 的可选配置。
+type autoRegisterOptions struct {
+	resyncInterval time.Duration
+	logger         *slog.Logger
+}
+
+// AutoRegisterOption 定制 AutoRegister 的行为。
+type AutoRegisterOption func(*autoRegisterOptions)
+
+// WithResyncInterval 启用周期性重新发现：每隔 interval 重新走一次 upstream 的 Server Reflection，
+// 并与上一次的发现结果比较，记录新增/消失的 service、method。
+//
+// 受限于 grpc-go 本身：Server.RegisterService 在 Server.Serve 之后调用会直接 Fatal 退出进程，
+// 而 grpc.UnknownServiceHandler 只能在构造 *grpc.Server 时通过 ServerOption 设置，事后无法补挂。
+// 因此重新发现到的新 service/method 不会被自动注册到已经在跑的 server 上——这一步只做漂移检测与
+// 日志告警，真正让新方法可代理仍然需要用新的发现结果重启代理进程（或重新调用 AutoRegister）。
+func WithResyncInterval(interval time.Duration) AutoRegisterOption {
+	return func(o *autoRegisterOptions) { o.resyncInterval = interval }
+}
+
+// WithLogger 设置 resync 日志使用的 logger，不设置时使用 slog.Default()。
+func WithLogger(logger *slog.Logger) AutoRegisterOption {
+	return func(o *autoRegisterOptions) { o.logger = logger }
+}
+
+// discoveredService 是一次 Server Reflection 发现得到的单个 service 及其全部方法名（不含 service 前缀）。
+type discoveredService struct {
+	name    string
+	methods []string
+}
+
+// AutoRegister 通过 upstream 的 gRPC Server Reflection（grpc.reflection.v1alpha.ServerReflection）
+// 发现其暴露的所有 service/method，按 filter 过滤后注册为代理 stream，作为介于“完全透明的
+// UnknownServiceHandler”与“手动逐个 RegisterService”之间的折中：无需手工列出方法名，
+// 但暴露面仍然由 filter 显式控制，而不是像 UnknownServiceHandler 那样对任意方法都放行。
+//
+// 必须在 server.Serve 之前调用：AutoRegister 内部通过 server.RegisterService 完成注册，
+// grpc-go 不允许在 Serve 之后注册新 service（会直接 Fatal 退出进程）。
+// 传入 WithResyncInterval 时会额外启动一个后台 goroutine 做周期性漂移检测，语义见该 Option 的注释；
+// 该 goroutine 随 ctx 取消而退出。
+// interceptors 语义与 TransparentHandler 一致：按顺序串联执行，作用于每个自动注册的 service/method。
+func AutoRegister(ctx context.Context, server *grpc.Server, director StreamDirector, upstream *grpc.ClientConn, filter Filter, interceptors []ProxyInterceptor, opts ...AutoRegisterOption) error {
+	cfg := &autoRegisterOptions{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	discovered, err := discoverServices(ctx, upstream)
+	if err != nil {
+		return fmt.Errorf("autoregister: discover upstream services: %w", err)
+	}
+
+	registerDiscovered(server, director, discovered, filter, interceptors)
+
+	if cfg.resyncInterval > 0 {
+		logger := cfg.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		go watchForDrift(ctx, upstream, discovered, filter, cfg.resyncInterval, logger)
+	}
+
+	return nil
+}
+
+// registerDiscovered 把 discovered 中经 filter 允许的方法注册到 server 上，
+// 每个 service 注册一次（没有任何方法被允许时跳过整个 service）。
+func registerDiscovered(server *grpc.Server, director StreamDirector, discovered []discoveredService, filter Filter, interceptors []ProxyInterceptor) {
+	// streamer 作为 service implementation 挂载在 server 上，所有发现到的 service 共用同一个；
+	// 与 TransparentHandler 一样经 ChainInterceptors 串联 interceptors，使 metrics/tracing/限流/日志等
+	// 横切关注点对自动注册的方法同样生效。
+	streamer := &Handler{director: director, interceptor: ChainInterceptors(interceptors...)}
+
+	for _, svc := range discovered {
+		var allowed []string
+		for _, m := range svc.methods {
+			if filter.Allow("/" + svc.name + "/" + m) {
+				allowed = append(allowed, m)
+			}
+		}
+
+		if len(allowed) == 0 {
+			// filter 排除了这个 service 下的所有方法，不必注册一个空壳 service。
+			continue
+		}
+
+		// fakeDesc 用于“伪造”一个服务描述，从而只暴露经 filter 允许的方法列表。
+		fakeDesc := &grpc.ServiceDesc{
+			ServiceName: svc.name,
+			HandlerType: (*interface{})(nil),
+		}
+
+		for _, m := range allowed {
+			fakeDesc.Streams = append(fakeDesc.Streams, grpc.StreamDesc{
+				StreamName:    m,
+				Handler:       streamer.Handler,
+				ServerStreams: true,
+				ClientStreams: true,
+			})
+		}
+
+		server.RegisterService(fakeDesc, streamer)
+	}
+}
+
+// watchForDrift 周期性重新发现 upstream 的 service/method，并把相对上一次快照新增/消失的、
+// 且满足 filter 的方法记录到日志中，直到 ctx 被取消。
+func watchForDrift(ctx context.Context, upstream *grpc.ClientConn, baseline []discoveredService, filter Filter, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	known := allowedMethodSet(baseline, filter)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			discovered, err := discoverServices(ctx, upstream)
+			if err != nil {
+				logger.Error("autoregister: resync failed", slog.String("error", err.Error()))
+				continue
+			}
+
+			current := allowedMethodSet(discovered, filter)
+			for m := range current {
+				if !known[m] {
+					logger.Warn("autoregister: new method discovered on upstream, restart to proxy it", slog.String("method", m))
+				}
+			}
+			for m := range known {
+				if !current[m] {
+					logger.Warn("autoregister: method no longer present on upstream", slog.String("method", m))
+				}
+			}
+
+			known = current
+		}
+	}
+}
+
+// allowedMethodSet 把 discovered 展开为满足 filter 的 fullMethodName 集合，便于比较两次发现结果的差异。
+func allowedMethodSet(discovered []discoveredService, filter Filter) map[string]bool {
+	set := make(map[string]bool)
+
+	for _, svc := range discovered {
+		for _, m := range svc.methods {
+			full := "/" + svc.name + "/" + m
+			if filter.Allow(full) {
+				set[full] = true
+			}
+		}
+	}
+
+	return set
+}
+
+// discoverServices 通过 Server Reflection 枚举 upstream 暴露的所有 service 及其方法名。
+func discoverServices(ctx context.Context, upstream *grpc.ClientConn) ([]discoveredService, error) {
+	client := grpc_reflection_v1alpha.NewServerReflectionClient(upstream)
+
+	stream, err := client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open reflection stream: %w", err)
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	serviceNames, err := listServices(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	requested := make(map[string]bool)
+
+	var discovered []discoveredService
+	for _, name := range serviceNames {
+		if name == serverReflectionServiceName {
+			continue
+		}
+
+		if err := resolveFileContainingSymbol(stream, name, files, requested); err != nil {
+			return nil, fmt.Errorf("resolve descriptor for service %q: %w", name, err)
+		}
+	}
+
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		fdSet.File = append(fdSet.File, fd)
+	}
+
+	registry, err := protodesc.NewFiles(fdSet)
+	if err != nil {
+		return nil, fmt.Errorf("build file registry: %w", err)
+	}
+
+	for _, name := range serviceNames {
+		if name == serverReflectionServiceName {
+			continue
+		}
+
+		svcDesc, err := findServiceDescriptor(registry, name)
+		if err != nil {
+			return nil, err
+		}
+
+		methods := svcDesc.Methods()
+		svc := discoveredService{name: name}
+		for i := 0; i < methods.Len(); i++ {
+			svc.methods = append(svc.methods, string(methods.Get(i).Name()))
+		}
+
+		discovered = append(discovered, svc)
+	}
+
+	return discovered, nil
+}
+
+// listServices 发送 ListServices 请求并返回所有 service 全名。
+func listServices(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoClient) ([]string, error) {
+	req := &grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_ListServices{},
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, fmt.Errorf("send ListServices: %w", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("recv ListServices response: %w", err)
+	}
+
+	listResp := resp.GetListServicesResponse()
+	if listResp == nil {
+		return nil, fmt.Errorf("unexpected reflection response for ListServices: %v", resp)
+	}
+
+	names := make([]string, 0, len(listResp.GetService()))
+	for _, s := range listResp.GetService() {
+		names = append(names, s.GetName())
+	}
+
+	return names, nil
+}
+
+// resolveFileContainingSymbol 拉取 symbolName（service 全名）所在的 FileDescriptorProto，
+// 并递归拉取其全部依赖，填入 files（按文件名去重，requested 记录已经请求过的文件名避免重复请求）。
+func resolveFileContainingSymbol(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoClient, symbolName string, files map[string]*descriptorpb.FileDescriptorProto, requested map[string]bool) error {
+	req := &grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: symbolName,
+		},
+	}
+
+	fds, err := sendReflectionFileRequest(stream, req)
+	if err != nil {
+		return err
+	}
+
+	return collectFileDescriptors(stream, fds, files, requested)
+}
+
+// resolveFileByFilename 拉取指定文件名对应的 FileDescriptorProto 及其依赖。
+func resolveFileByFilename(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoClient, filename string, files map[string]*descriptorpb.FileDescriptorProto, requested map[string]bool) error {
+	req := &grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_FileByFilename{
+			FileByFilename: filename,
+		},
+	}
+
+	fds, err := sendReflectionFileRequest(stream, req)
+	if err != nil {
+		return err
+	}
+
+	return collectFileDescriptors(stream, fds, files, requested)
+}
+
+// sendReflectionFileRequest 发送一次文件请求并解析出其中携带的 FileDescriptorProto 列表。
+func sendReflectionFileRequest(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoClient, req *grpc_reflection_v1alpha.ServerReflectionRequest) ([]*descriptorpb.FileDescriptorProto, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, fmt.Errorf("send reflection request: %w", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("recv reflection response: %w", err)
+	}
+
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, fmt.Errorf("upstream reflection error %d: %s", errResp.GetErrorCode(), errResp.GetErrorMessage())
+	}
+
+	fdResp := resp.GetFileDescriptorResponse()
+	if fdResp == nil {
+		return nil, fmt.Errorf("unexpected reflection response: %v", resp)
+	}
+
+	fds := make([]*descriptorpb.FileDescriptorProto, 0, len(fdResp.GetFileDescriptorProto()))
+	for _, raw := range fdResp.GetFileDescriptorProto() {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return nil, fmt.Errorf("decode FileDescriptorProto: %w", err)
+		}
+
+		fds = append(fds, fd)
+	}
+
+	return fds, nil
+}
+
+// collectFileDescriptors 把 fds 填入 files（按文件名去重），并递归拉取尚未见过的依赖文件。
+func collectFileDescriptors(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoClient, fds []*descriptorpb.FileDescriptorProto, files map[string]*descriptorpb.FileDescriptorProto, requested map[string]bool) error {
+	for _, fd := range fds {
+		name := fd.GetName()
+		if _, ok := files[name]; ok {
+			continue
+		}
+
+		files[name] = fd
+
+		for _, dep := range fd.GetDependency() {
+			if requested[dep] {
+				continue
+			}
+
+			// 提前标记为已请求，避免同一个依赖在多个 service 的发现过程中被重复请求。
+			requested[dep] = true
+
+			if err := resolveFileByFilename(stream, dep, files, requested); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
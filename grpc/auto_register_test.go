@@ -0,0 +1,168 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// fakeReflectionServer 是 grpc.reflection.v1alpha.ServerReflection 的最小实现：
+// ListServices 固定返回 services，FileContainingSymbol 固定返回 file（不含依赖），
+// 足够覆盖 AutoRegister 依赖的发现路径，无需真实 protoc 生成的 descriptor。
+type fakeReflectionServer struct {
+	grpc_reflection_v1alpha.UnimplementedServerReflectionServer
+
+	services []string
+	file     *descriptorpb.FileDescriptorProto
+}
+
+func (s *fakeReflectionServer) ServerReflectionInfo(stream grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := &grpc_reflection_v1alpha.ServerReflectionResponse{OriginalRequest: req}
+
+		switch req.MessageRequest.(type) {
+		case *grpc_reflection_v1alpha.ServerReflectionRequest_ListServices:
+			services := make([]*grpc_reflection_v1alpha.ServiceResponse, 0, len(s.services))
+			for _, name := range s.services {
+				services = append(services, &grpc_reflection_v1alpha.ServiceResponse{Name: name})
+			}
+			resp.MessageResponse = &grpc_reflection_v1alpha.ServerReflectionResponse_ListServicesResponse{
+				ListServicesResponse: &grpc_reflection_v1alpha.ListServiceResponse{Service: services},
+			}
+		case *grpc_reflection_v1alpha.ServerReflectionRequest_FileContainingSymbol:
+			raw, err := proto.Marshal(s.file)
+			if err != nil {
+				return err
+			}
+			resp.MessageResponse = &grpc_reflection_v1alpha.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &grpc_reflection_v1alpha.FileDescriptorResponse{FileDescriptorProto: [][]byte{raw}},
+			}
+		default:
+			resp.MessageResponse = &grpc_reflection_v1alpha.ServerReflectionResponse_ErrorResponse{
+				ErrorResponse: &grpc_reflection_v1alpha.ErrorResponse{ErrorMessage: "unsupported reflection request in test fake"},
+			}
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// startFakeReflectionUpstream 启动一个只暴露 Server Reflection 的 bufconn upstream，
+// 描述与 buildDemoFileDescriptorSet 相同的 acme.demo.v1.DemoService（Echo + Stream）。
+func startFakeReflectionUpstream(t *testing.T) *bufconn.Listener {
+	t.Helper()
+
+	lis := bufconn.Listen(bufConnSize)
+	srv := grpc.NewServer()
+
+	fdSet := buildDemoFileDescriptorSet()
+	grpc_reflection_v1alpha.RegisterServerReflectionServer(srv, &fakeReflectionServer{
+		services: []string{"acme.demo.v1.DemoService"},
+		file:     fdSet.File[0],
+	})
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	return lis
+}
+
+func TestAutoRegister_RegistersOnlyFilterAllowedMethods(t *testing.T) {
+	ctx := context.Background()
+
+	upstreamLis := startFakeReflectionUpstream(t)
+	upstreamConn, err := dialBufConn(ctx, upstreamLis)
+	if err != nil {
+		t.Fatalf("dial upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = upstreamConn.Close() })
+
+	proxySrv := grpc.NewServer()
+	filter := NewGlobFilter("!*/Stream")
+
+	if err := AutoRegister(ctx, proxySrv, DefaultDirector(upstreamConn), upstreamConn, filter, nil); err != nil {
+		t.Fatalf("AutoRegister: %v", err)
+	}
+
+	info, ok := proxySrv.GetServiceInfo()["acme.demo.v1.DemoService"]
+	if !ok {
+		t.Fatalf("expected acme.demo.v1.DemoService to be registered")
+	}
+
+	var methodNames []string
+	for _, m := range info.Methods {
+		methodNames = append(methodNames, m.Name)
+	}
+	if len(methodNames) != 1 || methodNames[0] != "Echo" {
+		t.Fatalf("expected only Echo to be registered, got %v", methodNames)
+	}
+}
+
+func TestAutoRegister_SkipsServiceWithNoAllowedMethods(t *testing.T) {
+	ctx := context.Background()
+
+	upstreamLis := startFakeReflectionUpstream(t)
+	upstreamConn, err := dialBufConn(ctx, upstreamLis)
+	if err != nil {
+		t.Fatalf("dial upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = upstreamConn.Close() })
+
+	proxySrv := grpc.NewServer()
+	filter := NewGlobFilter("!acme.demo.v1.*")
+
+	if err := AutoRegister(ctx, proxySrv, DefaultDirector(upstreamConn), upstreamConn, filter, nil); err != nil {
+		t.Fatalf("AutoRegister: %v", err)
+	}
+
+	if _, ok := proxySrv.GetServiceInfo()["acme.demo.v1.DemoService"]; ok {
+		t.Fatalf("expected acme.demo.v1.DemoService to be excluded entirely")
+	}
+}
+
+func TestNewGlobFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []string
+		method string
+		want   bool
+	}{
+		{"no rules allows everything", nil, "/acme.demo.v1.DemoService/Echo", true},
+		{"service name include matches", []string{"acme.demo.v1.*"}, "/acme.demo.v1.DemoService/Echo", true},
+		{"service name include rejects other service", []string{"acme.other.v1.*"}, "/acme.demo.v1.DemoService/Echo", false},
+		{"exclude wins over include", []string{"acme.demo.v1.*", "!*/Admin*"}, "/acme.demo.v1.DemoService/AdminReset", false},
+		{"path-style rule matches segment-wise", []string{"/acme.demo.v1.*/Echo"}, "/acme.demo.v1.DemoService/Echo", true},
+		{"path-style rule rejects different method", []string{"/acme.demo.v1.*/Echo"}, "/acme.demo.v1.DemoService/Stream", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewGlobFilter(tt.rules...)
+			if got := filter.Allow(tt.method); got != tt.want {
+				t.Fatalf("Allow(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
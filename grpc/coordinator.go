@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// streamCoordinator 协调一次代理调用中 inbound<->outbound 两个转发方向，取代原先
+// `for i := 0; i < 2; i++ { select { ... } }` 的写法。相比之下它额外处理：
+//   - serverStream 的 context 被取消（客户端断开/超时）时立即结束，不等待两个转发 goroutine
+//     自然返回；两个方向各自的 RawProtoFrame 仍通过其 defer f.Release() 释放池化 buffer。
+//   - 区分“半关闭”（入站 EOF，仅代表客户端发送方向结束）与“完全终止”（任一方向的非 EOF 错误），
+//     半关闭后继续等待出站方向，直到上游（可能是长连接的 server-streaming）自己结束。
+//   - 始终把首个非 EOF 错误作为最终 status 返回，同时尽量保留/透传已经拿到的 trailer。
+type streamCoordinator struct {
+	ctx          context.Context
+	serverStream grpc.ServerStream
+	clientStream grpc.ClientStream
+	clientCancel context.CancelFunc
+}
+
+// run 驱动两个转发方向直至调用结束，返回最终 trailer 与 status error（nil 表示成功）。
+func (c *streamCoordinator) run(inboundDone, outboundDone chan error) (metadata.MD, error) {
+	var trailer metadata.MD
+
+	// inboundHalfClosed 为 true 后，inboundDone 被置为 nil，select 不会再命中它，
+	// 只剩 ctx.Done() 与 outboundDone 两个分支，等待出站侧自行结束。
+	for {
+		select {
+		case <-c.ctx.Done():
+			// 客户端断开或调用超时：没有必要继续等待上游，取消 clientStream 促使两个方向的
+			// RecvMsg 尽快返回（各自 goroutine 会在返回前通过 defer 释放持有的 buffer）。
+			c.clientCancel()
+			return trailer, status.FromContextError(c.ctx.Err()).Err()
+
+		case inboundErr, ok := <-inboundDone:
+			if !ok {
+				// ok 为 false 只会出现在 channel 被关闭时；转发 goroutine 从不关闭它，理论上不会触发这个分支。
+				continue
+			}
+			// 之后不再关心入站方向，避免重复处理同一个已经半关闭/出错的方向。
+			inboundDone = nil
+
+			if inboundErr == io.EOF {
+				// 半关闭：入站发送方向正常结束，通知出站关闭发送，但继续等待出站响应
+				// （对 server-streaming 调用而言，上游此时完全可能还在持续发送）。
+				if cErr := c.clientStream.CloseSend(); cErr != nil {
+					c.clientCancel()
+					return trailer, cErr
+				}
+
+				continue
+			}
+
+			// 入站转发失败（非半关闭）：没有继续转发的意义，取消出站并把错误作为最终 status。
+			c.clientCancel()
+			return trailer, status.Errorf(codes.Internal, "failed proxying inbound to outbound: %v", inboundErr)
+
+		case outboundErr := <-outboundDone:
+			// 出站结束（无论成功与否）即为整个调用的终点：把 trailer 透传给入站连接。
+			trailer = c.clientStream.Trailer()
+			c.serverStream.SetTrailer(trailer)
+
+			if outboundErr != io.EOF {
+				// 出站返回了非 EOF 错误，直接透传（保持 gRPC status 语义）。
+				return trailer, outboundErr
+			}
+
+			// 出站正常结束。
+			return trailer, nil
+		}
+	}
+}
@@ -15,8 +15,8 @@ func DefaultDirector(cc *grpc.ClientConn) StreamDirector {
 	return func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
 		// 从 incoming context 读取 metadata（若不存在则 md 为空）。
 		md, _ := metadata.FromIncomingContext(ctx)
-		// 复制 metadata 到 outgoing context，避免复用导致并发写问题。
-		outgoingCtx := metadata.NewOutgoingContext(ctx, md.Copy())
+		// 剥离逐跳 header 并追加 x-forwarded-for/forwarded 链后再写入 outgoing context。
+		outgoingCtx := metadata.NewOutgoingContext(ctx, prepareOutgoingMetadata(ctx, md))
 		// 返回 outgoing context + 固定目标连接。
 		return outgoingCtx, cc, nil
 	}
@@ -2,10 +2,11 @@ package grpc
 
 import (
 	"context"
-	"io"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -22,18 +23,36 @@ var (
 // TransparentHandler 提供一个透明代理的方式：
 // - 代理作为 gRPC server 接收入站请求
 // - 代理作为 gRPC client 连接到目标 server 并转发请求
+// interceptors 为空时等同于无拦截器；非空时按顺序串联执行。
 // 返回值可以作为 grpc.UnknownServiceHandler 使用
-func TransparentHandler(director StreamDirector) grpc.StreamHandler {
+func TransparentHandler(director StreamDirector, interceptors ...ProxyInterceptor) grpc.StreamHandler {
 	// 每个 server 使用一个 handler 实例即可，director 决定如何路由到目标连接。
-	streamer := &Handler{director: director}
+	streamer := &Handler{director: director, interceptor: ChainInterceptors(interceptors...)}
 
 	// 返回 grpc.StreamHandler 供 UnknownServiceHandler 挂载。
 	return streamer.Handler
 }
 
+// RouterHandler 提供按 Router 规则转发的方式：
+// - 每次调用按 fullMethodName 匹配路由，经 LoadBalancer 挑选目标连接，并按 RetryPolicy 重试
+// - fallback 非 nil 时，未命中任何路由的方法仍转发到 fallback，否则直接返回 Unimplemented
+// 返回值可以作为 grpc.UnknownServiceHandler 使用
+func RouterHandler(router *Router, fallback *grpc.ClientConn, interceptors ...ProxyInterceptor) grpc.StreamHandler {
+	streamer := &Handler{router: router, fallback: fallback, interceptor: ChainInterceptors(interceptors...)}
+
+	return streamer.Handler
+}
+
 type Handler struct {
 	// director 根据 fullMethodName 选择目标连接，并可返回新的 outgoing context。
+	// 与 router 互斥：配置了 router 时，director 不会被使用。
 	director StreamDirector
+	// router 按匹配到的 Route 做负载均衡与重试，取代单一固定目标。
+	router *Router
+	// fallback 是 router 未命中任何规则时的兜底目标连接，可为 nil。
+	fallback *grpc.ClientConn
+	// interceptor 是配置给该 Handler 的拦截器（可能是 InterceptorChain），nil 视为空操作。
+	interceptor ProxyInterceptor
 }
 
 /*
@@ -41,7 +60,7 @@ type Handler struct {
 ** 就像调用任何 gRPC 服务端流一样。
 ** 使用 RawProtoFrame 类型作为载体，在输入流和输出流之间转发调用。
  */
-func (h *Handler) Handler(srv interface{}, serverStream grpc.ServerStream) error {
+func (h *Handler) Handler(srv interface{}, serverStream grpc.ServerStream) (err error) {
 	// 从 serverStream 提取完整方法名（形如 /package.Service/Method）。
 	fullMethodName, ok := grpc.MethodFromServerStream(serverStream)
 	if !ok {
@@ -49,75 +68,72 @@ func (h *Handler) Handler(srv interface{}, serverStream grpc.ServerStream) error
 		return status.Errorf(codes.Internal, "lowLevelServerStream does not exist in context")
 	}
 
-	// director 返回的上下文继承自 serverStream.Context()，并用于出站 client 侧。
-	outgoingCtx, targetConn, err := h.director(serverStream.Context(), fullMethodName)
+	interceptor := h.interceptorOrNoop()
+
+	// ctx 在 OnStreamStart 之前就取 serverStream.Context()：即使 OnStreamStart 短路失败，
+	// OnFinish 也要用和它配对的同一个 ctx 被调用，因此 defer 必须在调用 OnStreamStart 之前注册，
+	// 否则被拦截器（如 RateLimitInterceptor）拒绝的调用会让链上更早的拦截器（如 MetricsInterceptor）
+	// 泄漏已经累加的 in-flight 计数，并且完全不记录被拒绝的这次调用。
+	ctx := serverStream.Context()
+	var trailer metadata.MD
+	defer func() {
+		interceptor.OnFinish(ctx, err, trailer)
+	}()
+
+	// OnStreamStart 在解析目标之前调用，可用于鉴权/限流；返回 error 时直接短路，不会建立出站连接。
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, err = interceptor.OnStreamStart(ctx, fullMethodName, md)
 	if err != nil {
-		// director 决策失败（例如找不到目标连接、鉴权失败）直接向上返回。
 		return err
 	}
-	if targetConn == nil {
-		// 避免在 NewClientStream 处触发空指针或难定位错误。
-		return status.Errorf(codes.Unavailable, "target connection is nil")
-	}
-
-	// 使用可取消的 clientCtx，保证任一方向转发失败时能中止另一侧。
-	clientCtx, clientCancel := context.WithCancel(outgoingCtx)
-	defer clientCancel()
+	// 用携带了拦截器注入 context 的包装流替换原始 serverStream，后续 director/router 解析都基于它。
+	serverStream = &contextServerStream{ServerStream: serverStream, ctx: ctx}
 
-	// TODO(mwitkow): Add a `forwarded` header to metadata, https://en.wikipedia.org/wiki/X-Forwarded-For.
-	// 建立到目标 server 的出站 client stream，并强制使用代理 codec 以便按原始 bytes 转发。
-	clientStream, err := grpc.NewClientStream(clientCtx, clientStreamDescForProxying, targetConn, fullMethodName, DefaultClientCallOpts()...)
+	// establishClientStream 按是否配置了 router 选择固定目标或路由+负载均衡+重试路径，
+	// 返回的 clientCancel 用于保证任一方向转发失败时能中止另一侧。
+	_, clientCancel, clientStream, err := h.establishClientStream(serverStream, fullMethodName, interceptor)
 	if err != nil {
-		// 创建出站流失败（例如连接不可用）直接向上返回。
+		// 建立出站流失败（找不到目标、鉴权失败、重试耗尽等）直接向上返回。
 		return err
 	}
+	defer clientCancel()
 
 	// inboundToOutboundErrChan 负责把入站请求转发到出站 client stream。
-	inboundToOutboundErrChan := h.ForwardInboundToOutbound(serverStream, clientStream)
+	inboundToOutboundErrChan := h.forwardInboundToOutbound(ctx, serverStream, clientStream, interceptor)
 	// outboundToInboundErrChan 负责把出站响应转发回入站连接。
-	outboundToInboundErrChan := h.ForwardOutboundToInbound(clientStream, serverStream)
+	outboundToInboundErrChan := h.forwardOutboundToInbound(ctx, clientStream, serverStream, interceptor)
 
-	// 使用 select 语句进行非阻塞式等待, 避免程序陷入等待特定通道可读的死循环中。
-	for i := 0; i < 2; i++ {
-		select {
-		case inboundToOutboundErr := <-inboundToOutboundErrChan:
-			if inboundToOutboundErr == io.EOF {
-				// 入站已结束发送：向出站关闭发送方向，让目标 server 能结束读取。
-				if cCloseErr := clientStream.CloseSend(); cCloseErr != nil {
-					return cCloseErr
-				}
+	// coordinator 取代原先手写的两轮 select：额外处理 ctx 取消与半关闭/完全终止的区分，
+	// 详见 streamCoordinator 的注释。
+	coordinator := &streamCoordinator{ctx: ctx, serverStream: serverStream, clientStream: clientStream, clientCancel: clientCancel}
+	trailer, err = coordinator.run(inboundToOutboundErrChan, outboundToInboundErrChan)
 
-			} else {
-				// 入站转发失败：取消 clientCtx，尽快终止出站侧。
-				clientCancel()
-
-				return status.Errorf(codes.Internal, "failed proxying inbound to outbound: %v", inboundToOutboundErr)
-			}
-		case outboundToInboundErr := <-outboundToInboundErrChan:
-			// 将出站 trailer 透传到入站连接。
-			serverStream.SetTrailer(clientStream.Trailer())
+	return err
+}
 
-			if outboundToInboundErr != io.EOF {
-				// 出站返回了非 EOF 错误，直接透传（保持 gRPC status 语义）。
-				return outboundToInboundErr
-			}
+// interceptorOrNoop 返回 h.interceptor，未配置时返回一个空操作的 InterceptorChain，避免在 nil 接口上调用方法。
+func (h *Handler) interceptorOrNoop() ProxyInterceptor {
+	return orNoopInterceptor(h.interceptor)
+}
 
-			// 出站正常结束。
-			return nil
-		}
-	}
+// ForwardOutboundToInbound 保留给直接使用 Handler 的调用方，语义与 Handler.Handler 内部调用一致。
+func (h *Handler) ForwardOutboundToInbound(src grpc.ClientStream, dst grpc.ServerStream) chan error {
+	return h.forwardOutboundToInbound(dst.Context(), src, dst, h.interceptorOrNoop())
+}
 
-	// 理论上不会走到这里：两个方向的转发 goroutine 其一会先返回并触发 return。
-	return status.Errorf(codes.Internal, "gRPC proxying should never reach this stage.")
+// ForwardInboundToOutbound 保留给直接使用 Handler 的调用方，语义与 Handler.Handler 内部调用一致。
+func (h *Handler) ForwardInboundToOutbound(src grpc.ServerStream, dst grpc.ClientStream) chan error {
+	return h.forwardInboundToOutbound(src.Context(), src, dst, h.interceptorOrNoop())
 }
 
-func (h *Handler) ForwardOutboundToInbound(src grpc.ClientStream, dst grpc.ServerStream) chan error {
+func (h *Handler) forwardOutboundToInbound(ctx context.Context, src grpc.ClientStream, dst grpc.ServerStream, interceptor ProxyInterceptor) chan error {
 	// ret 用于把 goroutine 内的最终结果送回主协程。
 	ret := make(chan error, 1)
 
 	go func() {
-		// f 作为复用容器，承载原始 protobuf bytes。
+		// f 作为复用容器，承载原始 protobuf bytes；经 RawProtoCodecV2 解码时还持有池化 buffer 的引用。
 		f := &RawProtoFrame{}
+		defer f.Release()
 
 		// i 用于在第一条消息到来时透传出站 header。
 		for i := 0; ; i++ {
@@ -137,30 +153,42 @@ func (h *Handler) ForwardOutboundToInbound(src grpc.ClientStream, dst grpc.Serve
 					break
 				}
 
+				interceptor.OnHeader(ctx, md)
+
 				if err := dst.SendHeader(md); err != nil {
 					ret <- err
 					break
 				}
 			}
 
+			if err := interceptor.OnServerMsg(ctx, f); err != nil {
+				ret <- err
+				break
+			}
+
 			// 把出站消息转发回入站连接。
 			if err := dst.SendMsg(f); err != nil {
 				ret <- err
 				break
 			}
+
+			// SendMsg 已经把 f 的内容交给底层传输，归还本次 Recv 持有的池化 buffer，
+			// 避免下一轮 RecvMsg 复用同一个 f 时累积引用计数。
+			f.Release()
 		}
 	}()
 
 	return ret
 }
 
-func (h *Handler) ForwardInboundToOutbound(src grpc.ServerStream, dst grpc.ClientStream) chan error {
+func (h *Handler) forwardInboundToOutbound(ctx context.Context, src grpc.ServerStream, dst grpc.ClientStream, interceptor ProxyInterceptor) chan error {
 	// ret 用于把 goroutine 内的最终结果送回主协程。
 	ret := make(chan error, 1)
 
 	go func() {
-		// f 作为复用容器，承载原始 protobuf bytes。
+		// f 作为复用容器，承载原始 protobuf bytes；经 RawProtoCodecV2 解码时还持有池化 buffer 的引用。
 		f := &RawProtoFrame{}
+		defer f.Release()
 
 		for {
 			// 从入站连接接收一条消息。
@@ -169,13 +197,130 @@ func (h *Handler) ForwardInboundToOutbound(src grpc.ServerStream, dst grpc.Clien
 				break
 			}
 
+			if err := interceptor.OnClientMsg(ctx, f); err != nil {
+				ret <- err
+				break
+			}
+
 			// 把入站消息转发到出站。
 			if err := dst.SendMsg(f); err != nil {
 				ret <- err
 				break
 			}
+
+			// SendMsg 已经把 f 的内容交给底层传输，归还本次 Recv 持有的池化 buffer，
+			// 避免下一轮 RecvMsg 复用同一个 f 时累积引用计数。
+			f.Release()
 		}
 	}()
 
 	return ret
 }
+
+// establishClientStream 解析出站目标并建立 client stream。
+// 未配置 router 时沿用 director 的单一目标语义；配置了 router 时按路由做负载均衡与重试。
+// interceptor 用于在拨号前按需给 outgoing metadata 注入内容（见 outgoingMetadataInjector）。
+func (h *Handler) establishClientStream(serverStream grpc.ServerStream, fullMethodName string, interceptor ProxyInterceptor) (context.Context, context.CancelFunc, grpc.ClientStream, error) {
+	if h.router != nil {
+		return h.establishRoutedClientStream(serverStream, fullMethodName, interceptor)
+	}
+
+	// director 返回的上下文继承自 serverStream.Context()，并用于出站 client 侧。
+	outgoingCtx, targetConn, err := h.director(serverStream.Context(), fullMethodName)
+	if err != nil {
+		// director 决策失败（例如找不到目标连接、鉴权失败）直接向上返回。
+		return nil, nil, nil, err
+	}
+	if targetConn == nil {
+		// 避免在 NewClientStream 处触发空指针或难定位错误。
+		return nil, nil, nil, status.Errorf(codes.Unavailable, "target connection is nil")
+	}
+
+	return dialClientStream(outgoingCtx, targetConn, fullMethodName, interceptor)
+}
+
+// establishRoutedClientStream 解析 router 命中的 Route，挑选连接并在可重试错误上按 RetryPolicy 重试。
+// 重试只发生在 NewClientStream 建立阶段，已经开始转发消息的流不会被重放。
+func (h *Handler) establishRoutedClientStream(serverStream grpc.ServerStream, fullMethodName string, interceptor ProxyInterceptor) (context.Context, context.CancelFunc, grpc.ClientStream, error) {
+	outgoingCtx, timeoutCancel, route, err := h.router.Resolve(serverStream.Context(), fullMethodName)
+	if err != nil {
+		if h.fallback == nil {
+			// 没有兜底目标时，未命中路由直接报 Unimplemented。
+			return nil, nil, nil, err
+		}
+
+		// 未命中任何路由规则，回退到固定的 fallback 连接，outgoing metadata 的处理方式与 DefaultDirector 一致。
+		ctx := serverStream.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		fallbackCtx := metadata.NewOutgoingContext(ctx, prepareOutgoingMetadata(ctx, md))
+
+		return dialClientStream(fallbackCtx, h.fallback, fullMethodName, interceptor)
+	}
+
+	md, _ := metadata.FromOutgoingContext(outgoingCtx)
+
+	attempts := route.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		conn, pickErr := route.pick(md)
+		if pickErr != nil {
+			timeoutCancel()
+			return nil, nil, nil, pickErr
+		}
+
+		clientCtx, clientCancel, clientStream, err := dialClientStream(outgoingCtx, conn, fullMethodName, interceptor)
+		if err == nil {
+			// 把 perCallTimeout 的 cancel 与 client stream 自己的 cancel 折叠到一起返回，
+			// 调用方（Handler.Handler）只需 defer 一次就能保证两者都被释放。
+			combinedCancel := func() {
+				clientCancel()
+				timeoutCancel()
+			}
+			return clientCtx, combinedCancel, clientStream, nil
+		}
+
+		lastErr = err
+		if attempt == attempts || !route.retry.retryable(err) {
+			timeoutCancel()
+			return nil, nil, nil, err
+		}
+
+		timer := time.NewTimer(route.retry.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-serverStream.Context().Done():
+			// 调用方在 backoff 期间取消，不必再睡满整个退避时长才发现。
+			timer.Stop()
+			timeoutCancel()
+			return nil, nil, nil, status.FromContextError(serverStream.Context().Err()).Err()
+		}
+	}
+
+	timeoutCancel()
+	return nil, nil, nil, lastErr
+}
+
+// dialClientStream 基于 outgoingCtx 对 conn 发起 NewClientStream，并返回可取消的 clientCtx。
+// 若 interceptor 实现了 outgoingMetadataInjector，会在发起调用前让它向 outgoing metadata 写入内容。
+func dialClientStream(outgoingCtx context.Context, conn *grpc.ClientConn, fullMethodName string, interceptor ProxyInterceptor) (context.Context, context.CancelFunc, grpc.ClientStream, error) {
+	if injector, ok := interceptor.(outgoingMetadataInjector); ok {
+		md, _ := metadata.FromOutgoingContext(outgoingCtx)
+		md = md.Copy()
+		injector.InjectOutgoingTraceContext(outgoingCtx, md)
+		outgoingCtx = metadata.NewOutgoingContext(outgoingCtx, md)
+	}
+
+	clientCtx, clientCancel := context.WithCancel(outgoingCtx)
+
+	clientStream, err := grpc.NewClientStream(clientCtx, clientStreamDescForProxying, conn, fullMethodName, DefaultClientCallOptsV2()...)
+	if err != nil {
+		clientCancel()
+		return nil, nil, nil, err
+	}
+
+	return clientCtx, clientCancel, clientStream, nil
+}
@@ -0,0 +1,182 @@
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// HTTPGateway 把 `POST /v1/pkg.Service/Method` 形式的纯 HTTP/JSON 请求，转码为一次 unary gRPC 调用，
+// 面向那些无法直接说 gRPC 的调用方（浏览器表单、curl、老旧客户端等）。
+// 与 TranscodingCodec 一样，不需要在代理进程中编译任何 .pb.go 文件。
+type HTTPGateway struct {
+	conn  *grpc.ClientConn
+	files *protoregistry.Files
+}
+
+// NewHTTPGateway 基于 FileDescriptorSet 构建一个 HTTPGateway，所有请求都转发到 conn。
+func NewHTTPGateway(conn *grpc.ClientConn, fds *descriptorpb.FileDescriptorSet) (*HTTPGateway, error) {
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("http gateway: build file registry: %w", err)
+	}
+
+	return &HTTPGateway{conn: conn, files: files}, nil
+}
+
+// httpPathPrefix 是 REST 路径到 fullMethodName 映射所使用的固定前缀。
+const httpPathPrefix = "/v1/"
+
+func (g *HTTPGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fullMethod, ok := strings.CutPrefix(r.URL.Path, httpPathPrefix)
+	if !ok {
+		http.Error(w, "path must be /v1/pkg.Service/Method", http.StatusNotFound)
+		return
+	}
+	fullMethod = "/" + fullMethod
+
+	serviceName, methodName, err := splitFullMethod(fullMethod)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	svcDesc, err := findServiceDescriptor(g.files, serviceName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	methodDesc := svcDesc.Methods().ByName(protoreflect.Name(methodName))
+	if methodDesc == nil {
+		http.Error(w, fmt.Sprintf("method %q not found on service %q", methodName, serviceName), http.StatusNotFound)
+		return
+	}
+	if methodDesc.IsStreamingClient() || methodDesc.IsStreamingServer() {
+		// HTTPGateway 只按一次 unary 调用转码：对流式方法只 RecvMsg 一次会静默丢弃其余消息，
+		// 不如直接拒绝，让调用方知道该方法需要走 gRPC 而不是这个 REST 网关。
+		writeGRPCError(w, status.Errorf(codes.Unimplemented, "method %q is streaming, HTTPGateway only supports unary methods", fullMethod))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	reqMsg := dynamicpb.NewMessage(methodDesc.Input())
+	if err := protojson.Unmarshal(body, reqMsg); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	reqWire, err := proto.Marshal(reqMsg)
+	if err != nil {
+		http.Error(w, "failed to encode request", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = metadata.Join(md, headerToMetadata(r.Header))
+	outgoingCtx := metadata.NewOutgoingContext(ctx, prepareOutgoingMetadata(ctx, md))
+
+	stream, err := grpc.NewClientStream(outgoingCtx, clientStreamDescForProxying, g.conn, fullMethod, DefaultClientCallOpts()...)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	if err := stream.SendMsg(&RawProtoFrame{Payload: reqWire}); err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	if err := stream.CloseSend(); err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	respFrame := &RawProtoFrame{}
+	if err := stream.RecvMsg(respFrame); err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	respMsg := dynamicpb.NewMessage(methodDesc.Output())
+	if err := proto.Unmarshal(respFrame.Payload, respMsg); err != nil {
+		http.Error(w, "failed to decode upstream response", http.StatusInternalServerError)
+		return
+	}
+
+	respJSON, err := protojson.Marshal(respMsg)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respJSON)
+}
+
+// headerToMetadata 把 HTTP header 转换为 gRPC metadata，供透传给上游。
+func headerToMetadata(h http.Header) metadata.MD {
+	md := make(metadata.MD, len(h))
+	for k, vals := range h {
+		md.Append(strings.ToLower(k), vals...)
+	}
+
+	return md
+}
+
+// httpStatusFromCode 把 gRPC status code 映射为语义最接近的 HTTP 状态码。
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeGRPCError 把一个 gRPC error 按其 status code 映射为 HTTP 响应。
+func writeGRPCError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	http.Error(w, st.Message(), httpStatusFromCode(st.Code()))
+}
@@ -0,0 +1,129 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ProxyInterceptor 是 Handler 的扩展点：可以在不触碰转发核心逻辑的前提下插入鉴权、限流、日志、指标等横切关注点。
+// 各回调对应一次代理调用生命周期中的关键节点，ctx 始终是 OnStreamStart 返回的那个（可能已被前面的拦截器替换）。
+type ProxyInterceptor interface {
+	// OnStreamStart 在 director/router 解析目标之前调用，可基于 fullMethod/md 做鉴权或限流决策；
+	// 返回的 context 会替换后续转发使用的 context，返回 error 会直接终止调用（不会建立出站连接）。
+	OnStreamStart(ctx context.Context, fullMethod string, md metadata.MD) (context.Context, error)
+	// OnClientMsg 在每条从 inbound 客户端收到、即将转发给上游的消息上调用。
+	OnClientMsg(ctx context.Context, f *RawProtoFrame) error
+	// OnServerMsg 在每条从上游收到、即将转发回客户端的消息上调用。
+	OnServerMsg(ctx context.Context, f *RawProtoFrame) error
+	// OnHeader 在拿到上游响应 header、即将透传给客户端之前调用。
+	OnHeader(ctx context.Context, md metadata.MD)
+	// OnFinish 在调用结束时调用一次，err 为最终返回给客户端的错误（nil 表示成功），trailer 为透传的 trailer。
+	OnFinish(ctx context.Context, err error, trailer metadata.MD)
+}
+
+// outgoingMetadataInjector 是一个可选能力，而非 ProxyInterceptor 必须实现的义务：
+// 实现它的拦截器可以在出站 client stream 建立之前向 outgoing metadata 写入内容
+// （目前只有 TracingInterceptor 用它跨 inbound -> outbound 这一跳传播 trace 上下文）。
+// Handler 在 dialClientStream 前对配置的拦截器做类型断言，未实现时是空操作。
+type outgoingMetadataInjector interface {
+	InjectOutgoingTraceContext(ctx context.Context, md metadata.MD)
+}
+
+// BaseInterceptor 提供 ProxyInterceptor 的空实现，具体拦截器可以嵌入它，只覆盖关心的方法。
+type BaseInterceptor struct{}
+
+func (BaseInterceptor) OnStreamStart(ctx context.Context, _ string, _ metadata.MD) (context.Context, error) {
+	return ctx, nil
+}
+
+func (BaseInterceptor) OnClientMsg(context.Context, *RawProtoFrame) error { return nil }
+
+func (BaseInterceptor) OnServerMsg(context.Context, *RawProtoFrame) error { return nil }
+
+func (BaseInterceptor) OnHeader(context.Context, metadata.MD) {}
+
+func (BaseInterceptor) OnFinish(context.Context, error, metadata.MD) {}
+
+// InterceptorChain 把多个 ProxyInterceptor 串联成一个，按注册顺序依次调用；
+// 任意一个在 OnStreamStart/OnClientMsg/OnServerMsg 上返回 error 都会中止后续调用。
+type InterceptorChain []ProxyInterceptor
+
+// ChainInterceptors 组合多个 ProxyInterceptor 为一个，nil chain 表现为空操作。
+func ChainInterceptors(interceptors ...ProxyInterceptor) ProxyInterceptor {
+	return InterceptorChain(interceptors)
+}
+
+func (c InterceptorChain) OnStreamStart(ctx context.Context, fullMethod string, md metadata.MD) (context.Context, error) {
+	for _, i := range c {
+		var err error
+		ctx, err = i.OnStreamStart(ctx, fullMethod, md)
+		if err != nil {
+			return ctx, err
+		}
+	}
+
+	return ctx, nil
+}
+
+func (c InterceptorChain) OnClientMsg(ctx context.Context, f *RawProtoFrame) error {
+	for _, i := range c {
+		if err := i.OnClientMsg(ctx, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c InterceptorChain) OnServerMsg(ctx context.Context, f *RawProtoFrame) error {
+	for _, i := range c {
+		if err := i.OnServerMsg(ctx, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c InterceptorChain) OnHeader(ctx context.Context, md metadata.MD) {
+	for _, i := range c {
+		i.OnHeader(ctx, md)
+	}
+}
+
+func (c InterceptorChain) OnFinish(ctx context.Context, err error, trailer metadata.MD) {
+	for _, i := range c {
+		i.OnFinish(ctx, err, trailer)
+	}
+}
+
+// InjectOutgoingTraceContext 让 InterceptorChain 本身也满足 outgoingMetadataInjector：
+// 转发给链中每一个实现了该接口的拦截器（通常只有 TracingInterceptor 一个）。
+func (c InterceptorChain) InjectOutgoingTraceContext(ctx context.Context, md metadata.MD) {
+	for _, i := range c {
+		if injector, ok := i.(outgoingMetadataInjector); ok {
+			injector.InjectOutgoingTraceContext(ctx, md)
+		}
+	}
+}
+
+// orNoopInterceptor 把 nil 的 ProxyInterceptor 替换为空操作的 InterceptorChain，
+// 供各个 Handler 实现统一处理"未配置拦截器"的情况，避免在 nil 接口上调用方法。
+func orNoopInterceptor(interceptor ProxyInterceptor) ProxyInterceptor {
+	if interceptor == nil {
+		return InterceptorChain(nil)
+	}
+
+	return interceptor
+}
+
+// contextServerStream 包装 grpc.ServerStream 以替换其 Context()，
+// 用于把 OnStreamStart 返回的新 context 带入后续的 director/router 解析与转发流程。
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,236 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// gatherMetricValue 从 reg 中读出 name{labels...} 这一个时间序列的当前值（Gauge 或 Counter），
+// 用于断言 MetricsInterceptor 的效果，而不引入 prometheus/client_golang/testutil 这个额外依赖。
+func gatherMetricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			if !labelsMatch(m.GetLabel(), labels) {
+				continue
+			}
+			if mf.GetType() == dto.MetricType_GAUGE {
+				return m.GetGauge().GetValue()
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+
+	t.Fatalf("metric %q with labels %v not found", name, labels)
+	return 0
+}
+
+func labelsMatch(got []*dto.LabelPair, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, pair := range got {
+		if v, ok := want[pair.GetName()]; !ok || v != pair.GetValue() {
+			return false
+		}
+	}
+	return true
+}
+
+// rejectingInterceptor 在 OnStreamStart 上无条件拒绝，用于验证 InterceptorChain 在 OnStreamStart 上的短路行为。
+type rejectingInterceptor struct {
+	BaseInterceptor
+	err error
+}
+
+func (r *rejectingInterceptor) OnStreamStart(ctx context.Context, _ string, _ metadata.MD) (context.Context, error) {
+	return ctx, r.err
+}
+
+// recordingInterceptor 记录 OnStreamStart 与 OnFinish 被调用的次数，用于断言 InterceptorChain 的短路语义。
+type recordingInterceptor struct {
+	BaseInterceptor
+	onStreamStartCalls int
+	onFinishCalls      int
+}
+
+func (r *recordingInterceptor) OnStreamStart(ctx context.Context, _ string, _ metadata.MD) (context.Context, error) {
+	r.onStreamStartCalls++
+	return ctx, nil
+}
+
+func (r *recordingInterceptor) OnFinish(context.Context, error, metadata.MD) {
+	r.onFinishCalls++
+}
+
+func TestInterceptorChain_ShortCircuitsOnStreamStart(t *testing.T) {
+	first := &recordingInterceptor{}
+	rejecting := &rejectingInterceptor{err: status.Error(codes.ResourceExhausted, "rate limit exceeded")}
+	last := &recordingInterceptor{}
+
+	chain := ChainInterceptors(first, rejecting, last)
+
+	_, err := chain.OnStreamStart(context.Background(), "/acme.demo.v1.DemoService/Echo", nil)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if first.onStreamStartCalls != 1 {
+		t.Fatalf("expected interceptor before the rejecting one to run once, got %d", first.onStreamStartCalls)
+	}
+	if last.onStreamStartCalls != 0 {
+		t.Fatalf("expected interceptor after the rejecting one to be short-circuited, got %d calls", last.onStreamStartCalls)
+	}
+}
+
+// TestHandler_OnFinishCalledOnStreamStartRejection 是 chunk0-3 review 指出的回归测试：
+// MetricsInterceptor 与 RateLimitInterceptor 一起使用时，被限流拒绝的调用也必须让
+// streamsActive 归零、rpcCount 记一次 ResourceExhausted，而不是让 Handler 跳过 OnFinish。
+func TestHandler_OnFinishCalledOnStreamStartRejection(t *testing.T) {
+	blockForever := func(srv any, stream grpc.ServerStream) error {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+	targetLis := startCustomTargetServer(t, "acme.demo.v1.RateLimitedService", blockForever)
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(dialCancel)
+
+	targetConn, err := dialBufConn(dialCtx, targetLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = targetConn.Close() })
+
+	reg := prometheus.NewRegistry()
+	metrics := NewMetricsInterceptor(reg)
+	// Burst 为 0：第一次调用就会被拒绝，不必先消耗配额。
+	limiter := NewRateLimitInterceptor("", rate.Limit(0), 0)
+
+	proxyLis := bufconn.Listen(bufConnSize)
+	proxySrv := NewProxy(targetConn, WithInterceptors(metrics, limiter))
+	go func() { _ = proxySrv.Serve(proxyLis) }()
+	t.Cleanup(func() {
+		proxySrv.Stop()
+		_ = proxyLis.Close()
+	})
+
+	proxyConn, err := dialBufConn(dialCtx, proxyLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyConn.Close() })
+
+	fullMethod := "/acme.demo.v1.RateLimitedService/Call"
+	stream, err := grpc.NewClientStream(dialCtx, clientStreamDescForProxying, proxyConn, fullMethod, DefaultClientCallOptsV2()...)
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+
+	_ = stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")})
+	recvErr := stream.RecvMsg(&RawProtoFrame{})
+	if status.Code(recvErr) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", recvErr)
+	}
+
+	streamsActive := gatherMetricValue(t, reg, "go_proxy_streams_in_flight", map[string]string{"method": fullMethod})
+	if streamsActive != 0 {
+		t.Fatalf("expected streamsActive to be decremented back to 0 after rejection, got %v (OnFinish not called on this exit path)", streamsActive)
+	}
+
+	rejectedCount := gatherMetricValue(t, reg, "go_proxy_rpc_total", map[string]string{"method": fullMethod, "code": codes.ResourceExhausted.String()})
+	if rejectedCount != 1 {
+		t.Fatalf("expected one ResourceExhausted rpcCount sample, got %v", rejectedCount)
+	}
+}
+
+func TestRateLimitInterceptor_AllowsThenRejects(t *testing.T) {
+	l := NewRateLimitInterceptor("x-tenant", rate.Limit(0), 1)
+	md := metadata.Pairs("x-tenant", "acme")
+
+	if _, err := l.OnStreamStart(context.Background(), "/acme.demo.v1.DemoService/Echo", md); err != nil {
+		t.Fatalf("expected first call within burst to be allowed, got %v", err)
+	}
+
+	_, err := l.OnStreamStart(context.Background(), "/acme.demo.v1.DemoService/Echo", md)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected second call to exceed the burst of 1, got %v", err)
+	}
+
+	// 不同 key 独立计费，不受上面那个桶耗尽的影响。
+	otherMD := metadata.Pairs("x-tenant", "other")
+	if _, err := l.OnStreamStart(context.Background(), "/acme.demo.v1.DemoService/Echo", otherMD); err != nil {
+		t.Fatalf("expected a different tenant key to have its own bucket, got %v", err)
+	}
+}
+
+func TestAccessLogInterceptor_LogsOutcome(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	a := NewAccessLogInterceptor(logger)
+
+	ctx, err := a.OnStreamStart(context.Background(), "/acme.demo.v1.DemoService/Echo", nil)
+	if err != nil {
+		t.Fatalf("OnStreamStart: %v", err)
+	}
+
+	a.OnFinish(ctx, status.Error(codes.Unavailable, "upstream down"), nil)
+
+	out := buf.String()
+	if !bytes.Contains(buf.Bytes(), []byte("acme.demo.v1.DemoService/Echo")) {
+		t.Fatalf("expected log line to mention the method, got: %s", out)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(codes.Unavailable.String())) {
+		t.Fatalf("expected log line to mention the final status code, got: %s", out)
+	}
+}
+
+func TestTracingInterceptor_InjectOutgoingTraceContext(t *testing.T) {
+	// 直接指定 propagator（而不是依赖 otel 的全局默认值，它默认是空操作的 composite propagator），
+	// 使这个测试的结果不依赖进程里是否有别的代码调用过 otel.SetTextMapPropagator。
+	tr := &TracingInterceptor{propagator: propagation.TraceContext{}}
+
+	// 用一个已知合法、被采样的 SpanContext 驱动 ctx：这里只关心 InjectOutgoingTraceContext
+	// 是否正确地把"当前 ctx 里的 span"写到 outgoing metadata 上，不需要真正的 SDK TracerProvider
+	// 来生成 span（noop TracerProvider 产生的 SpanContext 是无效的，TraceContext.Inject 会直接跳过）。
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    [16]byte{1},
+		SpanID:     [8]byte{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	outMD := metadata.MD{}
+	tr.InjectOutgoingTraceContext(ctx, outMD)
+
+	if len(outMD.Get("traceparent")) == 0 {
+		t.Fatalf("expected InjectOutgoingTraceContext to set a traceparent header, got %v", outMD)
+	}
+}
@@ -0,0 +1,26 @@
+package grpc
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// NewJSONProxy 创建一个接受 JSON（经 TranscodingCodec）的代理 Server，转发到 dst 时仍使用原始 protobuf bytes，
+// 上游因此无需感知代理在做转码。fds 是调用方预先编译好的 FileDescriptorSet，代理本身不需要任何生成代码。
+// interceptors 语义与 NewProxy + WithInterceptors 一致，按顺序串联执行。
+//
+// 返回的 *grpc.Server 可以和 NewProxy 一样直接 Serve；如果调用方还需要接受纯 HTTP/JSON（非 gRPC）请求，
+// 使用 NewHTTPGateway 在同一个 dst 上构造一个 http.Handler。
+func NewJSONProxy(dst *grpc.ClientConn, fds *descriptorpb.FileDescriptorSet, interceptors []ProxyInterceptor, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	codec, err := NewTranscodingCodec(fds)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultOpts := []grpc.ServerOption{
+		grpc.ForceServerCodec(codec),
+		grpc.UnknownServiceHandler(NewTranscodingHandler(DefaultDirector(dst), interceptors...)),
+	}
+
+	return grpc.NewServer(append(defaultOpts, opts...)...), nil
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AccessLogInterceptor 为每次代理调用输出一条结构化访问日志：方法名、耗时、最终 status code，
+// 失败时再加上错误信息，便于不依赖 Prometheus 的场景下快速排障。日志不包含收发字节数。
+type AccessLogInterceptor struct {
+	BaseInterceptor
+
+	// Logger 为 nil 时使用 slog.Default()。
+	Logger *slog.Logger
+}
+
+// NewAccessLogInterceptor 创建一个使用给定 logger 的访问日志拦截器，logger 为 nil 时使用 slog.Default()。
+func NewAccessLogInterceptor(logger *slog.Logger) *AccessLogInterceptor {
+	return &AccessLogInterceptor{Logger: logger}
+}
+
+// accessLogStateKey 存放方法名与调用起始时间，供 OnFinish 渲染日志行。
+type accessLogStateKey struct{}
+
+type accessLogState struct {
+	method    string
+	startedAt time.Time
+}
+
+func (a *AccessLogInterceptor) OnStreamStart(ctx context.Context, fullMethod string, _ metadata.MD) (context.Context, error) {
+	return context.WithValue(ctx, accessLogStateKey{}, &accessLogState{method: fullMethod, startedAt: time.Now()}), nil
+}
+
+func (a *AccessLogInterceptor) OnFinish(ctx context.Context, err error, _ metadata.MD) {
+	state, _ := ctx.Value(accessLogStateKey{}).(*accessLogState)
+
+	logger := a.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	attrs := []any{
+		slog.String("grpc.code", status.Code(err).String()),
+	}
+	if state != nil {
+		attrs = append(attrs, slog.String("grpc.method", state.method), slog.Duration("grpc.duration", time.Since(state.startedAt)))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("grpc.error", err.Error()))
+		logger.Error("proxied rpc failed", attrs...)
+		return
+	}
+
+	logger.Info("proxied rpc", attrs...)
+}
@@ -0,0 +1,56 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// hopByHopMetadataKeys 是转发时应当剥离、不应透传给上游的 header。
+// te/content-length 是 HTTP/2 层面的逐跳头，grpc- 前缀的 key 由 gRPC 运行时自行维护，
+// 透传会与上游自身生成的同名 header 冲突。
+var hopByHopMetadataKeys = map[string]struct{}{
+	"te":             {},
+	"content-length": {},
+}
+
+// forwardedForKey / forwardedKey 是代理链路标识使用的 metadata header 名称。
+const (
+	forwardedForKey = "x-forwarded-for"
+	forwardedKey    = "forwarded"
+)
+
+// prepareOutgoingMetadata 基于入站 metadata 构造一份适合透传给上游的副本：
+// 剥离逐跳 header，并在 x-forwarded-for / forwarded 链上追加本跳的客户端地址，
+// 这样多级代理级联时上游仍能看到完整的调用链。
+func prepareOutgoingMetadata(ctx context.Context, md metadata.MD) metadata.MD {
+	out := md.Copy()
+
+	for key := range hopByHopMetadataKeys {
+		out.Delete(key)
+	}
+	// grpc- 前缀的 key（如 grpc-timeout、grpc-encoding）由运行时在每一跳重新生成，不应透传。
+	for key := range out {
+		if strings.HasPrefix(key, "grpc-") {
+			out.Delete(key)
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+
+		chain := append(out.Get(forwardedForKey), addr)
+		out.Set(forwardedForKey, strings.Join(chain, ", "))
+
+		forwardedEntry := "for=" + addr
+		if existing := out.Get(forwardedKey); len(existing) > 0 {
+			out.Set(forwardedKey, strings.Join(append(existing, forwardedEntry), ", "))
+		} else {
+			out.Set(forwardedKey, forwardedEntry)
+		}
+	}
+
+	return out
+}
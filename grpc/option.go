@@ -0,0 +1,31 @@
+package grpc
+
+import "google.golang.org/grpc"
+
+// proxyOptions 汇总 NewProxy 的可选配置，由 Option 逐个应用。
+type proxyOptions struct {
+	// router 非空时，代理按路由规则选择上游，dst 仅作为未命中任何路由时的兜底。
+	router *Router
+	// interceptors 按注册顺序串联执行，参见 ProxyInterceptor。
+	interceptors []ProxyInterceptor
+	// serverOpts 透传给底层 grpc.NewServer。
+	serverOpts []grpc.ServerOption
+}
+
+// Option 定制 NewProxy 创建出的代理行为。
+type Option func(*proxyOptions)
+
+// WithRouter 启用基于 Router 的多上游路由，取代仅转发到单一固定连接的默认行为。
+func WithRouter(router *Router) Option {
+	return func(o *proxyOptions) { o.router = router }
+}
+
+// WithServerOptions 透传任意 grpc.ServerOption（例如 TLS、keepalive 参数）。
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *proxyOptions) { o.serverOpts = append(o.serverOpts, opts...) }
+}
+
+// WithInterceptors 为代理注册一组 ProxyInterceptor，按传入顺序串联执行。
+func WithInterceptors(interceptors ...ProxyInterceptor) Option {
+	return func(o *proxyOptions) { o.interceptors = append(o.interceptors, interceptors...) }
+}
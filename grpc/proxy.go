@@ -4,17 +4,38 @@ import "google.golang.org/grpc"
 
 // NewProxy 创建一个透明代理 Server，并默认启用：
 // - 原始 protobuf bytes 转发 codec（server 侧）
-// - UnknownServiceHandler 透明转发（代理作为 client 连接到目标 server）
-func NewProxy(dst *grpc.ClientConn, opts ...grpc.ServerOption) *grpc.Server {
-	// defaultOpts 放在前面，允许调用方在 opts 中覆盖/追加行为。
-	defaultOpts := []grpc.ServerOption{DefaultProxyServerOpt(), DefaultProxyOpt(dst)}
+// - UnknownServiceHandler 透明转发（代理作为 client 连接到目标 server，或按 WithRouter 配置的路由规则转发）
+//
+// dst 在未使用 WithRouter 时作为唯一的固定上游；一旦传入 WithRouter，
+// dst 仅用于兜底（未命中任何路由时仍按 dst 转发），可以传 nil 以要求所有方法都必须命中路由。
+func NewProxy(dst *grpc.ClientConn, opts ...Option) *grpc.Server {
+	cfg := &proxyOptions{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// defaultOpts 放在前面，允许调用方通过 WithServerOptions 覆盖/追加行为。
+	// 默认走 CodecV2（零拷贝 Unmarshal + buffer 复用），RegisterService 等手工路径仍可显式使用 V1。
+	defaultOpts := []grpc.ServerOption{DefaultProxyServerOptV2(), proxyUnknownServiceOpt(dst, cfg.router, cfg.interceptors)}
 	// 将默认配置与调用方配置合并后创建 server。
-	return grpc.NewServer(append(defaultOpts, opts...)...)
+	return grpc.NewServer(append(defaultOpts, cfg.serverOpts...)...)
+}
+
+// proxyUnknownServiceOpt 按是否配置了 Router 选择合适的 UnknownServiceHandler。
+func proxyUnknownServiceOpt(dst *grpc.ClientConn, router *Router, interceptors []ProxyInterceptor) grpc.ServerOption {
+	if router != nil {
+		// RouterHandler 在每次调用时按方法名解析路由、挑选上游并按策略重试。
+		return grpc.UnknownServiceHandler(RouterHandler(router, dst, interceptors...))
+	}
+
+	// TransparentHandler 会把未知方法转发给 director 选择的目标连接（代理作为 client）。
+	return grpc.UnknownServiceHandler(TransparentHandler(DefaultDirector(dst), interceptors...))
 }
 
 // DefaultProxyOpt 返回 UnknownServiceHandler 配置，使 server 能转发“未注册的服务/方法”。
+//
+// Deprecated: 保留用于兼容直接使用 grpc.ServerOption 的调用方，新代码请使用 NewProxy + WithRouter。
 func DefaultProxyOpt(cc *grpc.ClientConn) grpc.ServerOption {
-	// TransparentHandler 会把未知方法转发给 director 选择的目标连接（代理作为 client）。
 	return grpc.UnknownServiceHandler(TransparentHandler(DefaultDirector(cc)))
 }
 
@@ -31,3 +52,19 @@ func DefaultClientCallOpts() []grpc.CallOption {
 	// ForceCodec 让 client stream 在 SendMsg/RecvMsg 时使用指定 codec。
 	return []grpc.CallOption{grpc.ForceCodec(RawProtoCodec{})}
 }
+
+// DefaultProxyServerOptV2 与 DefaultProxyServerOpt 等价，但使用 RawProtoCodecV2：
+// Unmarshal 直接引用 grpc-go 的池化 buffer，Payload 按需 materialize，避免 RawProtoCodec 每帧一次的
+// append 拷贝（纯原样转发时甚至完全不产生这次拷贝）。
+// NewProxy 默认使用这一版本；RegisterService 等手工搭建 server 的调用方仍可选择 V1。
+func DefaultProxyServerOptV2() grpc.ServerOption {
+	// ForceServerCodecV2 保证服务端侧使用指定 CodecV2 解/编码。
+	return grpc.ForceServerCodecV2(RawProtoCodecV2{})
+}
+
+// DefaultClientCallOptsV2 与 DefaultClientCallOpts 等价，但使用 RawProtoCodecV2，
+// 使 Handler 在原样转发时可以复用入站侧收到的池化 buffer，省去一次重新包装的拷贝。
+func DefaultClientCallOptsV2() []grpc.CallOption {
+	// ForceCodecV2 让 client stream 在 SendMsg/RecvMsg 时使用指定 CodecV2。
+	return []grpc.CallOption{grpc.ForceCodecV2(RawProtoCodecV2{})}
+}
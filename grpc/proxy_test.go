@@ -1,7 +1,9 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net"
 	"sync/atomic"
 	"testing"
@@ -53,16 +55,16 @@ func (b *testTargetServer) handler(srv any, stream grpc.ServerStream) error {
 	}
 
 	// 接收代理转发来的第一条消息。
-	req := &frame{}
+	req := &RawProtoFrame{}
 	if err := stream.RecvMsg(req); err != nil {
 		return err
 	}
 
 	// 构造响应 payload：原样回显并追加 "::ok" 标记。
-	respPayload := append([]byte(nil), req.payload...)
+	respPayload := append([]byte(nil), req.Payload...)
 	respPayload = append(respPayload, []byte("::ok")...)
 	// 发送响应消息回代理。
-	if err := stream.SendMsg(&frame{payload: respPayload}); err != nil {
+	if err := stream.SendMsg(&RawProtoFrame{Payload: respPayload}); err != nil {
 		return err
 	}
 
@@ -169,7 +171,7 @@ func TestTransparentProxy_ForwardsPayloadAndMetadata(t *testing.T) {
 	}
 
 	// 发送一条消息到 proxy。
-	if err := stream.SendMsg(&frame{payload: []byte("ping")}); err != nil {
+	if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
 		t.Fatalf("send: %v", err)
 	}
 	// 关闭发送方向，触发目标服务端 handler 返回。
@@ -178,12 +180,12 @@ func TestTransparentProxy_ForwardsPayloadAndMetadata(t *testing.T) {
 	}
 
 	// 接收目标服务端经由 proxy 转发回来的响应。
-	resp := &frame{}
+	resp := &RawProtoFrame{}
 	if err := stream.RecvMsg(resp); err != nil {
 		t.Fatalf("recv: %v", err)
 	}
 	// 断言 payload 被正确透传并由目标服务端追加 "::ok"。
-	if got, want := string(resp.payload), "ping::ok"; got != want {
+	if got, want := string(resp.Payload), "ping::ok"; got != want {
 		t.Fatalf("payload mismatch: got %q want %q", got, want)
 	}
 
@@ -240,13 +242,13 @@ func TestRegisterService_AllowsOnlySpecifiedMethods(t *testing.T) {
 	if err != nil {
 		t.Fatalf("new allowed stream: %v", err)
 	}
-	if err := allowedStream.SendMsg(&frame{payload: []byte("ping")}); err != nil {
+	if err := allowedStream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
 		t.Fatalf("allowed send: %v", err)
 	}
 	if err := allowedStream.CloseSend(); err != nil {
 		t.Fatalf("allowed close send: %v", err)
 	}
-	if err := allowedStream.RecvMsg(&frame{}); err != nil {
+	if err := allowedStream.RecvMsg(&RawProtoFrame{}); err != nil {
 		t.Fatalf("allowed recv: %v", err)
 	}
 
@@ -258,9 +260,358 @@ func TestRegisterService_AllowsOnlySpecifiedMethods(t *testing.T) {
 	// 关闭发送方向，触发服务端返回状态。
 	_ = deniedStream.CloseSend()
 	// 读取响应，此时应得到带 status 的 error。
-	err = deniedStream.RecvMsg(&frame{})
+	err = deniedStream.RecvMsg(&RawProtoFrame{})
 	// 断言 status code 为 Unimplemented（未注册方法）。
 	if status.Code(err) != codes.Unimplemented {
 		t.Fatalf("unexpected status code: %v (%v)", err, status.Code(err))
 	}
 }
+
+// benchEchoServer 是 benchmark 专用的回显服务：对每条消息原样返回，直到客户端半关闭（EOF）。
+// 与 testTargetServer 不同，它允许在同一个 stream 上反复收发，避免把建连开销计入每次迭代。
+type benchEchoServer struct{}
+
+func (benchEchoServer) handler(srv any, stream grpc.ServerStream) error {
+	for {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if err := stream.SendMsg(&RawProtoFrame{Payload: req.EnsurePayload()}); err != nil {
+			return err
+		}
+	}
+}
+
+// startBenchEchoServer 启动一个使用 RawProtoCodecV2 的回显服务，供基准测试直接拨号或经代理拨号。
+func startBenchEchoServer(b *testing.B) *bufconn.Listener {
+	b.Helper()
+
+	lis := bufconn.Listen(bufConnSize)
+	srv := grpc.NewServer(grpc.ForceServerCodecV2(RawProtoCodecV2{}))
+
+	echoSrv := benchEchoServer{}
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "acme.bench.v1.EchoService",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "Echo",
+				Handler:       echoSrv.handler,
+				ServerStreams: true,
+				ClientStreams: true,
+			},
+		},
+	}, echoSrv)
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	b.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	return lis
+}
+
+// benchmarkEcho 在一个长期存活的 stream 上反复发送/接收 payloadSize 大小的消息，
+// viaProxy 为 true 时经由 NewProxy 默认配置（RawProtoCodecV2）转发，否则直连回显服务，
+// 用于对比两者在 bytes/op、allocs/op 上的差异。
+func benchmarkEcho(b *testing.B, payloadSize int, viaProxy bool) {
+	b.Helper()
+
+	targetLis := startBenchEchoServer(b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	b.Cleanup(cancel)
+
+	targetConn, err := dialBufConn(ctx, targetLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		b.Fatalf("dial target: %v", err)
+	}
+	b.Cleanup(func() { _ = targetConn.Close() })
+
+	// dialLis 默认直连目标；viaProxy 时换成代理的 listener。
+	dialLis := targetLis
+	if viaProxy {
+		proxySrv := NewProxy(targetConn)
+		proxyLis := bufconn.Listen(bufConnSize)
+
+		go func() {
+			_ = proxySrv.Serve(proxyLis)
+		}()
+
+		b.Cleanup(func() {
+			proxySrv.Stop()
+			_ = proxyLis.Close()
+		})
+
+		dialLis = proxyLis
+	}
+
+	conn, err := dialBufConn(ctx, dialLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		b.Fatalf("dial: %v", err)
+	}
+	b.Cleanup(func() { _ = conn.Close() })
+
+	stream, err := grpc.NewClientStream(ctx, clientStreamDescForProxying, conn, "/acme.bench.v1.EchoService/Echo", DefaultClientCallOptsV2()...)
+	if err != nil {
+		b.Fatalf("new client stream: %v", err)
+	}
+	b.Cleanup(func() { _ = stream.CloseSend() })
+
+	payload := bytes.Repeat([]byte("a"), payloadSize)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(payloadSize))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := stream.SendMsg(&RawProtoFrame{Payload: payload}); err != nil {
+			b.Fatalf("send: %v", err)
+		}
+
+		resp := &RawProtoFrame{}
+		if err := stream.RecvMsg(resp); err != nil {
+			b.Fatalf("recv: %v", err)
+		}
+	}
+}
+
+func BenchmarkEcho_1KiB_Direct(b *testing.B)  { benchmarkEcho(b, 1024, false) }
+func BenchmarkEcho_1KiB_Proxy(b *testing.B)   { benchmarkEcho(b, 1024, true) }
+func BenchmarkEcho_64KiB_Direct(b *testing.B) { benchmarkEcho(b, 64*1024, false) }
+func BenchmarkEcho_64KiB_Proxy(b *testing.B)  { benchmarkEcho(b, 64*1024, true) }
+func BenchmarkEcho_1MiB_Direct(b *testing.B)  { benchmarkEcho(b, 1024*1024, false) }
+func BenchmarkEcho_1MiB_Proxy(b *testing.B)   { benchmarkEcho(b, 1024*1024, true) }
+
+// startCustomTargetServer 启动一个只注册单个方法（ServiceName/"Call"）的目标服务，
+// 供需要自定义 handler 行为（阻塞、中途报错、持续推送）的测试复用。
+func startCustomTargetServer(t *testing.T, serviceName string, handler func(srv any, stream grpc.ServerStream) error) *bufconn.Listener {
+	t.Helper()
+
+	lis := bufconn.Listen(bufConnSize)
+	srv := grpc.NewServer(grpc.ForceServerCodecV2(RawProtoCodecV2{}))
+
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "Call",
+				Handler:       handler,
+				ServerStreams: true,
+				ClientStreams: true,
+			},
+		},
+	}, struct{}{})
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	return lis
+}
+
+// dialThroughProxy 拨号一个指向 targetConn 的代理，并返回代理自身的连接，供测试直接发起调用。
+func dialThroughProxy(ctx context.Context, t *testing.T, targetConn *grpc.ClientConn) *grpc.ClientConn {
+	t.Helper()
+
+	proxyLis := bufconn.Listen(bufConnSize)
+	proxySrv := NewProxy(targetConn)
+
+	go func() {
+		_ = proxySrv.Serve(proxyLis)
+	}()
+
+	t.Cleanup(func() {
+		proxySrv.Stop()
+		_ = proxyLis.Close()
+	})
+
+	proxyConn, err := dialBufConn(ctx, proxyLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyConn.Close() })
+
+	return proxyConn
+}
+
+// TestHandler_ClientCancelMidStream 验证客户端在上游还没有响应时取消调用，
+// 代理能及时退出（而不是一直阻塞在 RecvMsg 上），客户端最终收到一个 context 相关的 status。
+func TestHandler_ClientCancelMidStream(t *testing.T) {
+	// blockUntilDone 在目标服务端收到第一条消息后，一直阻塞到 stream 的 context 被取消为止，
+	// 模拟“客户端已取消，但上游还不知道”的场景。
+	blockUntilDone := func(srv any, stream grpc.ServerStream) error {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+
+	targetLis := startCustomTargetServer(t, "acme.demo.v1.CancelService", blockUntilDone)
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(dialCancel)
+
+	targetConn, err := dialBufConn(dialCtx, targetLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = targetConn.Close() })
+
+	proxyConn := dialThroughProxy(dialCtx, t, targetConn)
+
+	// callCtx 独立于 dialCtx，以便在调用中途单独取消它。
+	callCtx, callCancel := context.WithCancel(context.Background())
+
+	stream, err := grpc.NewClientStream(callCtx, clientStreamDescForProxying, proxyConn, "/acme.demo.v1.CancelService/Call", DefaultClientCallOptsV2()...)
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+
+	if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// 取消调用而不等待响应，模拟客户端中途放弃。
+	callCancel()
+
+	// 代理与上游都应该很快因为 context 取消而放弃；用一个独立的超时守住测试本身，
+	// 避免协调逻辑有缺陷时导致测试挂死。
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.RecvMsg(&RawProtoFrame{})
+	}()
+
+	select {
+	case recvErr := <-done:
+		if status.Code(recvErr) != codes.Canceled {
+			t.Fatalf("expected Canceled, got: %v (%v)", recvErr, status.Code(recvErr))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RecvMsg did not return after client cancellation: possible goroutine leak")
+	}
+}
+
+// TestHandler_UpstreamAbort 验证上游在调用中途直接返回错误（相当于 HTTP/2 RST_STREAM）时，
+// 代理把该错误原样透传给客户端，而不是挂起或返回一个无关的状态。
+func TestHandler_UpstreamAbort(t *testing.T) {
+	abortAfterFirstMsg := func(srv any, stream grpc.ServerStream) error {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+
+		return status.Errorf(codes.Aborted, "upstream aborted the stream")
+	}
+
+	targetLis := startCustomTargetServer(t, "acme.demo.v1.AbortService", abortAfterFirstMsg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	targetConn, err := dialBufConn(ctx, targetLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = targetConn.Close() })
+
+	proxyConn := dialThroughProxy(ctx, t, targetConn)
+
+	stream, err := grpc.NewClientStream(ctx, clientStreamDescForProxying, proxyConn, "/acme.demo.v1.AbortService/Call", DefaultClientCallOptsV2()...)
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+
+	if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	_ = stream.CloseSend()
+
+	err = stream.RecvMsg(&RawProtoFrame{})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted, got: %v (%v)", err, status.Code(err))
+	}
+}
+
+// TestHandler_ServerStreamingAfterClientHalfClose 验证客户端半关闭（发送一条消息后 CloseSend）之后，
+// 上游仍可以作为长连接的 server-streaming 调用持续推送多条消息，代理不会因为入站 EOF 而提前结束转发。
+func TestHandler_ServerStreamingAfterClientHalfClose(t *testing.T) {
+	const pushCount = 3
+
+	streamAfterHalfClose := func(srv any, stream grpc.ServerStream) error {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+
+		// 确认客户端已经半关闭（第二次 RecvMsg 应该立刻拿到 EOF），再继续推送响应。
+		if err := stream.RecvMsg(&RawProtoFrame{}); err != io.EOF {
+			return status.Errorf(codes.Internal, "expected client half-close, got: %v", err)
+		}
+
+		for i := 0; i < pushCount; i++ {
+			if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("push")}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	targetLis := startCustomTargetServer(t, "acme.demo.v1.StreamingService", streamAfterHalfClose)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	targetConn, err := dialBufConn(ctx, targetLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = targetConn.Close() })
+
+	proxyConn := dialThroughProxy(ctx, t, targetConn)
+
+	stream, err := grpc.NewClientStream(ctx, clientStreamDescForProxying, proxyConn, "/acme.demo.v1.StreamingService/Call", DefaultClientCallOptsV2()...)
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+
+	if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("close send: %v", err)
+	}
+
+	for i := 0; i < pushCount; i++ {
+		resp := &RawProtoFrame{}
+		if err := stream.RecvMsg(resp); err != nil {
+			t.Fatalf("recv #%d: %v", i, err)
+		}
+		if string(resp.EnsurePayload()) != "push" {
+			t.Fatalf("recv #%d: unexpected payload %q", i, resp.EnsurePayload())
+		}
+	}
+
+	if err := stream.RecvMsg(&RawProtoFrame{}); err != io.EOF {
+		t.Fatalf("expected io.EOF after final push, got: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimitInterceptor 对每个 key（默认取自某个 metadata header，例如 tenant id 或 API key）
+// 维护一个独立的令牌桶，超出速率的调用在 OnStreamStart 阶段就被拒绝，不会建立出站连接。
+type RateLimitInterceptor struct {
+	BaseInterceptor
+
+	// Header 是限流 key 所在的 metadata header 名称；为空时所有调用共用同一个桶。
+	Header string
+	// Rate 是令牌桶的恢复速率（每秒生成的令牌数）。
+	Rate rate.Limit
+	// Burst 是令牌桶的容量上限。
+	Burst int
+
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+}
+
+// NewRateLimitInterceptor 创建一个按 header 取 key 的限流拦截器。
+func NewRateLimitInterceptor(header string, r rate.Limit, burst int) *RateLimitInterceptor {
+	return &RateLimitInterceptor{
+		Header:  header,
+		Rate:    r,
+		Burst:   burst,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+func (l *RateLimitInterceptor) OnStreamStart(ctx context.Context, _ string, md metadata.MD) (context.Context, error) {
+	key := ""
+	if l.Header != "" {
+		if vals := md.Get(l.Header); len(vals) > 0 {
+			key = vals[0]
+		}
+	}
+
+	if !l.limiterFor(key).AllowN(time.Now(), 1) {
+		return ctx, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for key %q", key)
+	}
+
+	return ctx, nil
+}
+
+// limiterFor 返回 key 对应的令牌桶，首次访问时惰性创建。
+func (l *RateLimitInterceptor) limiterFor(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.buckets[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.Rate, l.Burst)
+		l.buckets[key] = limiter
+	}
+
+	return limiter
+}
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
 )
 
 // RawProtoFrame 是代理层的“消息容器”：
@@ -9,7 +10,46 @@ import (
 // - RawProtoFrame 会把 RecvMsg/SendMsg 的 v 识别为 *RawProtoFrame，并直接读写 payload
 type RawProtoFrame struct {
 	// payload 为一条 protobuf message 的原始序列化结果。
+	// 经由 RawProtoCodecV2 解码得到的 frame 上，Payload 在 materialized 变为 true 之前保持为 nil——
+	// 纯原样转发（只看 buf、不读内容）的场景不需要付这次拷贝，调用 EnsurePayload 时才按需触发（Len 不会触发）。
 	Payload []byte
+
+	// buf 仅在经由 RawProtoCodecV2 解码时非空，引用 grpc-go 的池化 buffer（见 raw_proto_codec_v2.go）。
+	// materialized 为 true 时 Payload 已经从它 materialize 出来；Release 时一并归还引用计数。
+	buf          mem.BufferSlice
+	materialized bool
+}
+
+// Len 返回本条消息的字节数，优先读 buf 的长度（无需 materialize），
+// 仅在 frame 并非来自 RawProtoCodecV2（buf 为 nil）时才退回 len(Payload)。
+func (f *RawProtoFrame) Len() int {
+	if f.buf != nil {
+		return f.buf.Len()
+	}
+	return len(f.Payload)
+}
+
+// EnsurePayload 返回本条消息的 protobuf wire bytes，在 buf 尚未 materialize 时按需触发一次拷贝
+// 并缓存结果，供需要读取消息内容的拦截器（日志、内容路由等）使用；只看长度时优先用 Len 以避免这次拷贝。
+func (f *RawProtoFrame) EnsurePayload() []byte {
+	if !f.materialized && f.buf != nil {
+		f.Payload = f.buf.Materialize()
+		f.materialized = true
+	}
+	return f.Payload
+}
+
+// Release 归还 f 持有的池化 buffer（如果有），并清空 Payload。
+// 在 CodecV2 路径之外构造的 RawProtoFrame（buf 为 nil）上调用是安全的空操作。
+// 调用方必须保证 Release 之后不再读取 Payload：底层内存可能已被复用。
+func (f *RawProtoFrame) Release() {
+	if f.buf != nil {
+		f.buf.Free()
+		f.buf = nil
+	}
+
+	f.Payload = nil
+	f.materialized = false
 }
 
 // RawProtoCodec 是代理 codec：
@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
+)
+
+// RawProtoCodecV2 是 RawProtoCodec 的 encoding.CodecV2 版本：
+// - Unmarshal 直接接收 grpc-go 池化的 mem.BufferSlice 并持有其引用，不会像 RawProtoCodec.Unmarshal
+//   的 `append(f.Payload[:0], data...)` 那样立即拷贝一整帧；Payload 保持为 nil，直到调用方真正
+//   通过 RawProtoFrame.EnsurePayload 读取内容时才按需 materialize（Len 只读 buf 长度，不拷贝），
+//   原样转发路径完全不付这次拷贝。
+// - Marshal 在 v 持有未释放的 f.buf 时直接复用该 buffer 转发出去（典型的 proxy 原样转发场景），
+//   不会为已经池化的数据重新分配/拷贝一份。
+//
+// 与 RawProtoCodec 一样，名称保持 "proto"，使用方式是在 ForceServerCodecV2/ForceCodecV2 上
+// 二选一启用，不能与 RawProtoCodec 同时对同一条连接生效。
+type RawProtoCodecV2 struct{}
+
+func (RawProtoCodecV2) Name() string {
+	return BaseProtoCodec.Name()
+}
+
+func (RawProtoCodecV2) Marshal(v any) (mem.BufferSlice, error) {
+	f, ok := v.(*RawProtoFrame)
+	if !ok {
+		// 非代理路径：回退到标准 proto 编解码，再包装成单一 buffer。
+		data, err := BaseProtoCodec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return mem.BufferSlice{mem.NewBuffer(&data, nil)}, nil
+	}
+
+	if f.buf != nil {
+		// f 仍持有解码时的池化 buffer（典型的转发场景：原样收、原样发），直接复用，零拷贝。
+		f.buf.Ref()
+		return f.buf, nil
+	}
+
+	// f 是调用方直接构造的（例如转码/HTTP 网关路径），没有关联的池化 buffer，
+	// 包一层 mem.Buffer 即可；pool 传 nil 使 Free 成为空操作。
+	return mem.BufferSlice{mem.NewBuffer(&f.Payload, nil)}, nil
+}
+
+func (RawProtoCodecV2) Unmarshal(data mem.BufferSlice, v any) error {
+	f, ok := v.(*RawProtoFrame)
+	if !ok {
+		// 非代理路径：回退到标准 proto 编解码。
+		return BaseProtoCodec.Unmarshal(data.Materialize(), v)
+	}
+
+	// 释放 f 上一次 Recv 遗留的 buffer 引用，避免复用同一个 frame 时泄漏引用计数。
+	if f.buf != nil {
+		f.buf.Free()
+	}
+
+	// 持有这次的 buffer（Ref 配合 grpc-go 收到消息后自身的 Free，保证生命周期独立于调用方是否及时处理）。
+	data.Ref()
+	f.buf = data
+	// 不在这里 materialize：Payload 留空，等到有人真正调用 EnsurePayload 才按需拷贝
+	// （只需要长度时调用 Len 直接读 buf，不拷贝），纯原样转发的调用不会产生这次分配。
+	f.Payload = nil
+	f.materialized = false
+
+	return nil
+}
+
+var _ encoding.CodecV2 = RawProtoCodecV2{}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/mem"
+)
+
+// TestRawProtoCodecV2_UnmarshalDoesNotMaterializeEagerly 是 review 指出的回归测试：
+// Unmarshal 不应在解码时就无条件拷贝整帧，Payload 应当保持未 materialize，直到调用方
+// 通过 EnsurePayload 真正需要内容时才按需触发；只读长度的 Len 不应触发。
+func TestRawProtoCodecV2_UnmarshalDoesNotMaterializeEagerly(t *testing.T) {
+	pool := mem.DefaultBufferPool()
+	buf := pool.Get(len("hello"))
+	copy(*buf, "hello")
+	data := mem.BufferSlice{mem.NewBuffer(buf, pool)}
+
+	f := &RawProtoFrame{}
+	if err := (RawProtoCodecV2{}).Unmarshal(data, f); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	t.Cleanup(f.Release)
+
+	if f.Payload != nil {
+		t.Fatalf("expected Payload to stay nil until EnsurePayload is called, got %q", f.Payload)
+	}
+	if got := f.Len(); got != len("hello") {
+		t.Fatalf("expected Len() to report the buffer length without materializing, got %d", got)
+	}
+	if f.Payload != nil {
+		t.Fatalf("expected Len() to not trigger materialization, Payload is %q", f.Payload)
+	}
+
+	if got := string(f.EnsurePayload()); got != "hello" {
+		t.Fatalf("expected EnsurePayload to materialize the wire bytes, got %q", got)
+	}
+}
+
+// TestRawProtoCodecV2_MarshalReusesBufWithoutMaterializing 验证原样转发（f.buf 非空）路径下
+// Marshal 直接复用池化 buffer，不需要 Payload 曾经被 materialize 过。
+func TestRawProtoCodecV2_MarshalReusesBufWithoutMaterializing(t *testing.T) {
+	pool := mem.DefaultBufferPool()
+	buf := pool.Get(len("world"))
+	copy(*buf, "world")
+	data := mem.BufferSlice{mem.NewBuffer(buf, pool)}
+
+	f := &RawProtoFrame{}
+	if err := (RawProtoCodecV2{}).Unmarshal(data, f); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	t.Cleanup(f.Release)
+
+	out, err := (RawProtoCodecV2{}).Marshal(f)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	defer out.Free()
+
+	if got := string(out.Materialize()); got != "world" {
+		t.Fatalf("marshal output mismatch: got %q want %q", got, "world")
+	}
+	if f.Payload != nil {
+		t.Fatalf("expected the passthrough Marshal path to avoid materializing Payload, got %q", f.Payload)
+	}
+}
@@ -0,0 +1,304 @@
+package grpc
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Pool 表示一组可互相替代的上游连接，按 LoadBalancer 策略从中选取一个使用。
+type Pool struct {
+	// Name 是 Pool 的标识，仅用于日志/调试，不参与路由匹配。
+	Name string
+	// conns 是该 Pool 持有的目标连接集合，顺序固定以配合轮询策略。
+	conns []*grpc.ClientConn
+}
+
+// NewPool 创建一个命名的上游连接池，conns 不能为空。
+func NewPool(name string, conns ...*grpc.ClientConn) *Pool {
+	// 复制一份底层切片，避免调用方后续修改入参影响 Pool 内部状态。
+	owned := make([]*grpc.ClientConn, len(conns))
+	copy(owned, conns)
+
+	return &Pool{Name: name, conns: owned}
+}
+
+// LoadBalancer 从一个 Pool 中按策略挑选一个目标连接。
+// pick 为本次调用的选择依据（例如一致性哈希所需的 key），可为空字符串。
+type LoadBalancer interface {
+	Pick(pool *Pool, pick string) (*grpc.ClientConn, error)
+}
+
+// roundRobinBalancer 按顺序轮询 Pool 中的连接。
+type roundRobinBalancer struct {
+	// next 记录下一次应选取的下标，使用原子操作保证并发安全。
+	next uint64
+}
+
+// RoundRobin 返回一个轮询式 LoadBalancer。
+func RoundRobin() LoadBalancer {
+	return &roundRobinBalancer{}
+}
+
+func (b *roundRobinBalancer) Pick(pool *Pool, _ string) (*grpc.ClientConn, error) {
+	if len(pool.conns) == 0 {
+		return nil, status.Errorf(codes.Unavailable, "pool %q has no upstream connections", pool.Name)
+	}
+
+	// 原子自增后取模，得到本次选择的下标，多个 goroutine 并发调用时依旧均匀分布。
+	idx := atomic.AddUint64(&b.next, 1) - 1
+	return pool.conns[int(idx%uint64(len(pool.conns)))], nil
+}
+
+// randomBalancer 在 Pool 中随机挑选一个连接。
+type randomBalancer struct {
+	// mu 保护 rand.Rand 这个非并发安全的源。
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// Random 返回一个随机选择式 LoadBalancer。
+func Random() LoadBalancer {
+	return &randomBalancer{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (b *randomBalancer) Pick(pool *Pool, _ string) (*grpc.ClientConn, error) {
+	if len(pool.conns) == 0 {
+		return nil, status.Errorf(codes.Unavailable, "pool %q has no upstream connections", pool.Name)
+	}
+
+	b.mu.Lock()
+	idx := b.rnd.Intn(len(pool.conns))
+	b.mu.Unlock()
+
+	return pool.conns[idx], nil
+}
+
+// consistentHashBalancer 依据 pick（通常是某个 metadata header 的值）做一致性哈希，
+// 以实现会话亲和：相同 key 总是落在同一个连接上，除非 Pool 成员数量发生变化。
+type consistentHashBalancer struct{}
+
+// ConsistentHash 返回一个按 key 做一致性哈希的 LoadBalancer，常用于会话亲和场景。
+// 具体 key 由调用方（Router）从请求 metadata 中提取后传入 Pick。
+func ConsistentHash() LoadBalancer {
+	return consistentHashBalancer{}
+}
+
+func (consistentHashBalancer) Pick(pool *Pool, pick string) (*grpc.ClientConn, error) {
+	if len(pool.conns) == 0 {
+		return nil, status.Errorf(codes.Unavailable, "pool %q has no upstream connections", pool.Name)
+	}
+
+	if pick == "" {
+		// 没有可用的亲和 key 时退化为固定选第一个，保持行为确定。
+		return pool.conns[0], nil
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(pick))
+	idx := int(h.Sum32()) % len(pool.conns)
+	if idx < 0 {
+		idx += len(pool.conns)
+	}
+
+	return pool.conns[idx], nil
+}
+
+// RetryPolicy 描述一个路由在选中的上游调用失败时的重试行为。
+// 重试只发生在 grpc.NewClientStream 尚未成功建立流之前，已经转发过消息的流不会被重放。
+type RetryPolicy struct {
+	// MaxAttempts 是总尝试次数（含首次），<= 1 表示不重试。
+	MaxAttempts int
+	// RetryableCodes 是允许重试的 gRPC status code 集合，默认 Unavailable 与 ResourceExhausted。
+	RetryableCodes []codes.Code
+	// BaseBackoff 是首次重试前的等待时间，之后按 2^n 指数增长。
+	BaseBackoff time.Duration
+	// MaxBackoff 是退避时间的上限。
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy 返回一组保守的默认重试参数。
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		RetryableCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+		BaseBackoff:    50 * time.Millisecond,
+		MaxBackoff:     1 * time.Second,
+	}
+}
+
+// retryable 判断某个 error 是否允许按策略重试。
+func (p RetryPolicy) retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff 返回第 attempt 次重试（从 1 开始）前应等待的时长。
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseBackoff << uint(attempt-1)
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	return d
+}
+
+// Route 描述一条路由规则：匹配 fullMethodName 的 pattern，以及命中后的转发策略。
+type Route struct {
+	// pattern 支持 "*" 通配单个 path 段内的任意字符，例如 "/pkg.Service/*"、"/pkg.*/*"。
+	pattern string
+	// pool 是该路由命中后可供选择的目标连接集合。
+	pool *Pool
+	// balancer 决定如何从 pool 中挑选连接，默认为 RoundRobin。
+	balancer LoadBalancer
+	// affinityHeader 非空时，balancer 收到的 pick key 取自该 metadata header。
+	affinityHeader string
+	// retry 是该路由的重试策略，零值表示不重试。
+	retry RetryPolicy
+	// perCallTimeout 为 0 表示不对出站调用施加额外的超时上限。
+	perCallTimeout time.Duration
+}
+
+// RouteOption 用于在 Router.Add 时定制单条路由的行为。
+type RouteOption func(*Route)
+
+// WithBalancer 指定该路由使用的 LoadBalancer，默认为 RoundRobin。
+func WithBalancer(b LoadBalancer) RouteOption {
+	return func(r *Route) { r.balancer = b }
+}
+
+// WithAffinityHeader 指定用于一致性哈希/亲和选择的 metadata header 名称。
+func WithAffinityHeader(header string) RouteOption {
+	return func(r *Route) { r.affinityHeader = header }
+}
+
+// WithRetryPolicy 指定该路由的重试策略。
+func WithRetryPolicy(p RetryPolicy) RouteOption {
+	return func(r *Route) { r.retry = p }
+}
+
+// WithPerCallTimeout 为命中该路由的调用设置一个硬性 deadline 上限，
+// 实际 deadline 取 "调用方已有 deadline" 与 "now + timeout" 中更早的一个。
+func WithPerCallTimeout(timeout time.Duration) RouteOption {
+	return func(r *Route) { r.perCallTimeout = timeout }
+}
+
+// Router 按 fullMethodName 把请求分派到一个已注册的 Pool。
+// 规则按注册顺序依次匹配，命中第一条即停止。
+type Router struct {
+	mu     sync.RWMutex
+	routes []*Route
+}
+
+// NewRouter 创建一个空的 Router，需要通过 Add 注册路由规则。
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Add 注册一条路由规则，pattern 与 DefaultDirector 的固定目标不同，
+// 支持用 "*" 通配 fullMethodName 中的 service 或 method 段。
+func (r *Router) Add(pattern string, pool *Pool, opts ...RouteOption) *Router {
+	route := &Route{
+		pattern:  pattern,
+		pool:     pool,
+		balancer: RoundRobin(),
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+
+	r.mu.Lock()
+	r.routes = append(r.routes, route)
+	r.mu.Unlock()
+
+	return r
+}
+
+// match 返回第一条匹配 fullMethodName 的路由，未命中时返回 nil。
+func (r *Router) match(fullMethodName string) *Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, route := range r.routes {
+		if globMatch(route.pattern, fullMethodName) {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// Resolve 解析 fullMethodName 对应的 Route，并返回附带了 outgoing metadata 的 context。
+// 未命中任何路由时返回 codes.Unimplemented。
+// 返回的 CancelFunc 在 route 配置了 WithPerCallTimeout 时才对应一个真正的 timer，否则是空操作；
+// 调用方必须在所有路径（成功建立 client stream、重试、失败）上都调用它一次，避免 timer 泄漏到
+// 整个 perCallTimeout 耗尽为止——建立 client stream 成功时应把它与该 client stream 的 cancel 折叠到一起。
+func (r *Router) Resolve(ctx context.Context, fullMethodName string) (context.Context, context.CancelFunc, *Route, error) {
+	route := r.match(fullMethodName)
+	if route == nil {
+		return ctx, func() {}, nil, status.Errorf(codes.Unimplemented, "no route registered for method %q", fullMethodName)
+	}
+
+	outgoingCtx := ctx
+	md, _ := metadata.FromIncomingContext(ctx)
+	outgoingCtx = metadata.NewOutgoingContext(outgoingCtx, prepareOutgoingMetadata(ctx, md))
+
+	cancel := context.CancelFunc(func() {})
+	if route.perCallTimeout > 0 {
+		outgoingCtx, cancel = context.WithTimeout(outgoingCtx, route.perCallTimeout)
+	}
+
+	return outgoingCtx, cancel, route, nil
+}
+
+// pick 从 route 中按其 balancer 与亲和 header 选择一个目标连接。
+func (route *Route) pick(md metadata.MD) (*grpc.ClientConn, error) {
+	key := ""
+	if route.affinityHeader != "" {
+		if vals := md.Get(route.affinityHeader); len(vals) > 0 {
+			key = vals[0]
+		}
+	}
+
+	return route.balancer.Pick(route.pool, key)
+}
+
+// globMatch 判断 fullMethodName 是否匹配 pattern，pattern 中的 "*" 通配一个 path 段内的任意内容。
+// 例如 "/pkg.Service/*" 匹配 "/pkg.Service/Method"，"/pkg.*/*" 匹配任意两段式方法名。
+func globMatch(pattern, name string) bool {
+	patternSegs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	nameSegs := strings.Split(strings.TrimPrefix(name, "/"), "/")
+
+	if len(patternSegs) != len(nameSegs) {
+		return false
+	}
+
+	for i, seg := range patternSegs {
+		ok, err := path.Match(seg, nameSegs[i])
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,394 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		method  string
+		want    bool
+	}{
+		{"exact match", "/acme.demo.v1.DemoService/Echo", "/acme.demo.v1.DemoService/Echo", true},
+		{"wildcard method", "/acme.demo.v1.DemoService/*", "/acme.demo.v1.DemoService/Echo", true},
+		{"wildcard service prefix", "/acme.*/*", "/acme.demo.v1.DemoService/Echo", true},
+		{"wildcard service no match", "/other.*/*", "/acme.demo.v1.DemoService/Echo", false},
+		{"wildcard both segments", "/*/*", "/acme.demo.v1.DemoService/Echo", true},
+		{"mismatched segment count", "/acme.demo.v1.DemoService/*", "/acme.demo.v1.DemoService/Sub/Echo", false},
+		{"no match", "/acme.demo.v1.DemoService/Echo", "/acme.demo.v1.DemoService/Other", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := globMatch(tt.pattern, tt.method); got != tt.want {
+				t.Fatalf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundRobinBalancer_CyclesThroughConns(t *testing.T) {
+	connA, connB := &grpc.ClientConn{}, &grpc.ClientConn{}
+	pool := NewPool("p", connA, connB)
+	b := RoundRobin()
+
+	var picks []*grpc.ClientConn
+	for i := 0; i < 4; i++ {
+		conn, err := b.Pick(pool, "")
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		picks = append(picks, conn)
+	}
+
+	want := []*grpc.ClientConn{connA, connB, connA, connB}
+	for i := range want {
+		if picks[i] != want[i] {
+			t.Fatalf("pick %d: got %p want %p", i, picks[i], want[i])
+		}
+	}
+}
+
+func TestRoundRobinBalancer_EmptyPoolReturnsUnavailable(t *testing.T) {
+	pool := NewPool("empty")
+	if _, err := RoundRobin().Pick(pool, ""); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestRandomBalancer_PicksFromPool(t *testing.T) {
+	connA, connB := &grpc.ClientConn{}, &grpc.ClientConn{}
+	pool := NewPool("p", connA, connB)
+	b := Random()
+
+	for i := 0; i < 10; i++ {
+		conn, err := b.Pick(pool, "")
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		if conn != connA && conn != connB {
+			t.Fatalf("pick returned a connection outside the pool: %p", conn)
+		}
+	}
+}
+
+func TestConsistentHashBalancer_StableForSameKey(t *testing.T) {
+	pool := NewPool("p", &grpc.ClientConn{}, &grpc.ClientConn{}, &grpc.ClientConn{})
+	b := ConsistentHash()
+
+	first, err := b.Pick(pool, "tenant-a")
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		conn, err := b.Pick(pool, "tenant-a")
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		if conn != first {
+			t.Fatalf("expected the same key to always land on the same connection, got a different one on attempt %d", i)
+		}
+	}
+}
+
+func TestConsistentHashBalancer_NoKeyFallsBackToFirst(t *testing.T) {
+	connA := &grpc.ClientConn{}
+	pool := NewPool("p", connA, &grpc.ClientConn{})
+
+	conn, err := ConsistentHash().Pick(pool, "")
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	if conn != connA {
+		t.Fatalf("expected empty key to fall back to the first connection")
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	p := DefaultRetryPolicy()
+
+	if !p.retryable(status.Error(codes.Unavailable, "down")) {
+		t.Fatalf("expected Unavailable to be retryable")
+	}
+	if !p.retryable(status.Error(codes.ResourceExhausted, "limited")) {
+		t.Fatalf("expected ResourceExhausted to be retryable")
+	}
+	if p.retryable(status.Error(codes.InvalidArgument, "bad request")) {
+		t.Fatalf("expected InvalidArgument to not be retryable")
+	}
+	if p.retryable(nil) {
+		t.Fatalf("expected nil error to not be retryable")
+	}
+}
+
+func TestRetryPolicy_Backoff_ExponentialWithCap(t *testing.T) {
+	p := RetryPolicy{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 35 * time.Millisecond}
+
+	if got := p.backoff(1); got != 10*time.Millisecond {
+		t.Fatalf("attempt 1: got %v want 10ms", got)
+	}
+	if got := p.backoff(2); got != 20*time.Millisecond {
+		t.Fatalf("attempt 2: got %v want 20ms", got)
+	}
+	if got := p.backoff(3); got != 35*time.Millisecond {
+		t.Fatalf("attempt 3: expected to be capped at 35ms, got %v", got)
+	}
+}
+
+func TestPrepareOutgoingMetadata_StripsHopByHopAndChainsForwardedFor(t *testing.T) {
+	md := metadata.Pairs(
+		"te", "trailers",
+		"content-length", "123",
+		"grpc-timeout", "1S",
+		"x-forwarded-for", "1.1.1.1",
+		"x-tenant", "acme",
+	)
+
+	out := prepareOutgoingMetadata(context.Background(), md)
+
+	for _, key := range []string{"te", "content-length", "grpc-timeout"} {
+		if len(out.Get(key)) != 0 {
+			t.Fatalf("expected hop-by-hop key %q to be stripped, got %v", key, out.Get(key))
+		}
+	}
+	if got := out.Get("x-tenant"); len(got) != 1 || got[0] != "acme" {
+		t.Fatalf("expected unrelated metadata to pass through unchanged, got %v", got)
+	}
+	// 没有 peer 信息时（测试用 context 不带 peer），x-forwarded-for 原样保留，不会被清空或追加空值。
+	if got := out.Get("x-forwarded-for"); len(got) != 1 || got[0] != "1.1.1.1" {
+		t.Fatalf("expected existing x-forwarded-for to be left untouched without peer info, got %v", got)
+	}
+}
+
+func TestRouter_Resolve_NoRouteReturnsUnimplemented(t *testing.T) {
+	r := NewRouter()
+
+	_, cancel, route, err := r.Resolve(context.Background(), "/acme.demo.v1.DemoService/Echo")
+	defer cancel()
+
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented, got %v", err)
+	}
+	if route != nil {
+		t.Fatalf("expected a nil route on a miss, got %+v", route)
+	}
+}
+
+// TestRouter_Resolve_PerCallTimeoutCancelReleasesTimer 是 review 指出的回归测试：
+// Resolve 在配置了 WithPerCallTimeout 时必须把 context.WithTimeout 的 CancelFunc 交还给调用方，
+// 否则即便调用立刻成功，超时计时器也会一直挂到整个 perCallTimeout 耗尽才被回收。
+func TestRouter_Resolve_PerCallTimeoutCancelReleasesTimer(t *testing.T) {
+	r := NewRouter().Add("/acme.demo.v1.DemoService/*", NewPool("p", &grpc.ClientConn{}), WithPerCallTimeout(time.Hour))
+
+	ctx, cancel, route, err := r.Resolve(context.Background(), "/acme.demo.v1.DemoService/Echo")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if route == nil {
+		t.Fatalf("expected a matched route")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected the resolved context to still be live before cancel, got %v", ctx.Err())
+	}
+
+	// 调用方在拿到 client stream 后应当能立刻释放这个超时计时器，而不用等满 1 小时。
+	cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected cancel() to immediately close the per-call-timeout context, it is still live")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected Canceled after cancel(), got %v", ctx.Err())
+	}
+}
+
+func TestRouter_Resolve_NoTimeoutCancelIsNoop(t *testing.T) {
+	r := NewRouter().Add("/acme.demo.v1.DemoService/*", NewPool("p", &grpc.ClientConn{}))
+
+	ctx, cancel, route, err := r.Resolve(context.Background(), "/acme.demo.v1.DemoService/Echo")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if route == nil {
+		t.Fatalf("expected a matched route")
+	}
+
+	cancel()
+	if ctx.Err() != nil {
+		t.Fatalf("expected the resolved context to be unaffected by cancel() when no perCallTimeout is set, got %v", ctx.Err())
+	}
+}
+
+// echoWithTagHandler 回显收到的第一条消息，并在 payload 末尾追加 tag，用于辨别负载均衡把调用
+// 分发到了哪一个后端。
+func echoWithTagHandler(tag string) func(srv any, stream grpc.ServerStream) error {
+	return func(srv any, stream grpc.ServerStream) error {
+		req := &RawProtoFrame{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		resp := append(append([]byte(nil), req.EnsurePayload()...), []byte("::"+tag)...)
+		return stream.SendMsg(&RawProtoFrame{Payload: resp})
+	}
+}
+
+func dialRoutedTarget(t *testing.T, serviceName string, handler func(srv any, stream grpc.ServerStream) error) *grpc.ClientConn {
+	t.Helper()
+
+	lis := startCustomTargetServer(t, serviceName, handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := dialBufConn(ctx, lis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func callRoutedProxy(t *testing.T, proxyConn *grpc.ClientConn, fullMethod string, affinity string, affinityValue string) (string, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if affinity != "" {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(affinity, affinityValue))
+	}
+
+	stream, err := grpc.NewClientStream(ctx, clientStreamDescForProxying, proxyConn, fullMethod, DefaultClientCallOptsV2()...)
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+	if err := stream.SendMsg(&RawProtoFrame{Payload: []byte("ping")}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	resp := &RawProtoFrame{}
+	err = stream.RecvMsg(resp)
+	return string(resp.EnsurePayload()), err
+}
+
+// TestRouterHandler_RetriesAcrossPoolOnDialFailure 验证 Router 命中路由后，若选中的连接在
+// 建立 client stream 阶段就失败（重试只发生在这一步，已经开始转发消息的流不会被重放），
+// 会按 RetryPolicy 重试并用同一个 balancer 重新挑选连接，而不是直接把错误透传给客户端。
+func TestRouterHandler_RetriesAcrossPoolOnDialFailure(t *testing.T) {
+	// failingConn 已经 Close，NewClientStream 会立刻返回 Canceled，模拟该后端此刻不可用。
+	failingConn := dialRoutedTarget(t, "acme.demo.v1.RoutedService", echoWithTagHandler("A"))
+	_ = failingConn.Close()
+	okConn := dialRoutedTarget(t, "acme.demo.v1.RoutedService", echoWithTagHandler("B"))
+
+	pool := NewPool("routed", failingConn, okConn)
+	router := NewRouter().Add("/acme.demo.v1.RoutedService/*", pool,
+		WithBalancer(RoundRobin()),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, RetryableCodes: []codes.Code{codes.Canceled}, BaseBackoff: 5 * time.Millisecond}),
+	)
+
+	proxyLis := bufconn.Listen(bufConnSize)
+	proxySrv := NewProxy(nil, WithRouter(router))
+	go func() { _ = proxySrv.Serve(proxyLis) }()
+	t.Cleanup(func() {
+		proxySrv.Stop()
+		_ = proxyLis.Close()
+	})
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dialCancel()
+	proxyConn, err := dialBufConn(dialCtx, proxyLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyConn.Close() })
+
+	got, err := callRoutedProxy(t, proxyConn, "/acme.demo.v1.RoutedService/Call", "", "")
+	if err != nil {
+		t.Fatalf("expected the retry to eventually succeed, got %v", err)
+	}
+	if got != "ping::B" {
+		t.Fatalf("expected the retried call to land on the healthy backend, got %q", got)
+	}
+}
+
+// TestRouterHandler_AffinityHeaderSticksToSameBackend 验证 WithAffinityHeader + ConsistentHash
+// 组合下，携带相同 key 的多次调用总是落在同一个后端上。
+func TestRouterHandler_AffinityHeaderSticksToSameBackend(t *testing.T) {
+	connA := dialRoutedTarget(t, "acme.demo.v1.AffinityService", echoWithTagHandler("A"))
+	connB := dialRoutedTarget(t, "acme.demo.v1.AffinityService", echoWithTagHandler("B"))
+
+	pool := NewPool("affinity", connA, connB)
+	router := NewRouter().Add("/acme.demo.v1.AffinityService/*", pool,
+		WithBalancer(ConsistentHash()),
+		WithAffinityHeader("x-tenant"),
+	)
+
+	proxyLis := bufconn.Listen(bufConnSize)
+	proxySrv := NewProxy(nil, WithRouter(router))
+	go func() { _ = proxySrv.Serve(proxyLis) }()
+	t.Cleanup(func() {
+		proxySrv.Stop()
+		_ = proxyLis.Close()
+	})
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dialCancel()
+	proxyConn, err := dialBufConn(dialCtx, proxyLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyConn.Close() })
+
+	first, err := callRoutedProxy(t, proxyConn, "/acme.demo.v1.AffinityService/Call", "x-tenant", "acme")
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		got, err := callRoutedProxy(t, proxyConn, "/acme.demo.v1.AffinityService/Call", "x-tenant", "acme")
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if got != first {
+			t.Fatalf("expected the same affinity key to stick to the same backend, call %d got %q want %q", i, got, first)
+		}
+	}
+}
+
+// TestRouterHandler_NoFallbackReturnsUnimplemented 验证未命中任何路由且没有配置 fallback 时，
+// 代理直接把 Unimplemented 返回给客户端，而不是转发到某个默认目标。
+func TestRouterHandler_NoFallbackReturnsUnimplemented(t *testing.T) {
+	router := NewRouter().Add("/acme.demo.v1.RoutedService/*", NewPool("p", &grpc.ClientConn{}))
+
+	proxyLis := bufconn.Listen(bufConnSize)
+	proxySrv := NewProxy(nil, WithRouter(router))
+	go func() { _ = proxySrv.Serve(proxyLis) }()
+	t.Cleanup(func() {
+		proxySrv.Stop()
+		_ = proxyLis.Close()
+	})
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dialCancel()
+	proxyConn, err := dialBufConn(dialCtx, proxyLis, grpc.WithDefaultCallOptions(DefaultClientCallOptsV2()...))
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxyConn.Close() })
+
+	_, err = callRoutedProxy(t, proxyConn, "/acme.demo.v1.UnroutedService/Call", "", "")
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented for an unmatched method with no fallback, got %v", err)
+	}
+}
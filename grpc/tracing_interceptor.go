@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+// mdTextMapCarrier 把 gRPC metadata 适配为 propagation.TextMapCarrier，
+// 使 traceparent 之类的 header 可以用标准 W3C propagator 注入/提取。
+type mdTextMapCarrier metadata.MD
+
+func (c mdTextMapCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+
+	return vals[0]
+}
+
+func (c mdTextMapCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c mdTextMapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// TracingInterceptor 为每次代理调用创建一个 span：从 inbound metadata 中提取上游 traceparent（若有），
+// 并把新 span 的上下文注入到出站 metadata，使链路追踪能跨越 inbound -> outbound 这一跳延续下去。
+type TracingInterceptor struct {
+	BaseInterceptor
+
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewTracingInterceptor 创建一个使用全局 TracerProvider/TextMapPropagator 的 TracingInterceptor。
+func NewTracingInterceptor() *TracingInterceptor {
+	return &TracingInterceptor{
+		tracer:     otel.Tracer("fireflycore/go-proxy"),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+// tracingSpanKey 存放当前调用的 span，供 OnFinish 结束它。
+type tracingSpanKey struct{}
+
+func (t *TracingInterceptor) OnStreamStart(ctx context.Context, fullMethod string, md metadata.MD) (context.Context, error) {
+	// 从 inbound metadata 提取上游已有的 trace 上下文（若客户端本身也在链路追踪中）。
+	extracted := t.propagator.Extract(ctx, mdTextMapCarrier(md.Copy()))
+
+	spanCtx, span := t.tracer.Start(extracted, fullMethod, trace.WithSpanKind(trace.SpanKindServer))
+	span.SetAttributes(attribute.String("rpc.method", fullMethod), attribute.String("rpc.system", "grpc"))
+
+	return context.WithValue(spanCtx, tracingSpanKey{}, span), nil
+}
+
+func (t *TracingInterceptor) OnFinish(ctx context.Context, err error, _ metadata.MD) {
+	span, ok := ctx.Value(tracingSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		st := grpcStatus.Convert(err)
+		span.SetStatus(codes.Error, st.Message())
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", st.Code().String()))
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+
+	span.End()
+}
+
+// InjectOutgoingTraceContext 把 ctx 上当前 span 的上下文写入 md，供出站调用前调用，
+// 以便上游（或下一跳代理）能继续这条 trace。Handler 在建立出站 client stream 前经 outgoingMetadataInjector 自动调用它。
+func (t *TracingInterceptor) InjectOutgoingTraceContext(ctx context.Context, md metadata.MD) {
+	t.propagator.Inject(ctx, mdTextMapCarrier(md))
+}
@@ -0,0 +1,152 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Direction 标记 TranscodingFrame 承载的是某个方法的请求消息还是响应消息，
+// 二者在 FileDescriptorSet 中对应不同的 MessageDescriptor。
+type Direction int
+
+const (
+	// DirectionRequest 对应方法的 input type。
+	DirectionRequest Direction = iota
+	// DirectionResponse 对应方法的 output type。
+	DirectionResponse
+)
+
+// TranscodingFrame 是 TranscodingCodec 的消息容器：
+// - FullMethod/Direction 由调用方（TranscodingHandler/HTTPGateway）填入，用于解析出正确的 MessageDescriptor
+// - Payload 保存原始 protobuf 消息 bytes（与 RawProtoFrame 语义一致，便于在两者之间转换）
+type TranscodingFrame struct {
+	FullMethod string
+	Direction  Direction
+	Payload    []byte
+}
+
+// TranscodingCodec 是一个 encoding.Codec：在 wire 上收发 JSON，但 Payload 始终保存原始 protobuf bytes，
+// 从而可以直接复用现有转发管道（client 侧仍然是 RawProtoCodec，对上游完全透明）。
+//
+// 消息类型通过 protoreflect 动态解析，不需要在代理上生成/编译任何 .pb.go 文件，
+// 与 RawProtoCodec「网关不持有 proto 类型」的设计目标一致。
+type TranscodingCodec struct {
+	files *protoregistry.Files
+}
+
+// NewTranscodingCodec 基于一份编译好的 FileDescriptorSet 构建 TranscodingCodec。
+// fds 通常由 protoc --descriptor_set_out 生成，调用方无需在代理进程中引入任何生成代码。
+func NewTranscodingCodec(fds *descriptorpb.FileDescriptorSet) (*TranscodingCodec, error) {
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("transcoding: build file registry: %w", err)
+	}
+
+	return &TranscodingCodec{files: files}, nil
+}
+
+func (c *TranscodingCodec) Name() string {
+	return "json"
+}
+
+func (c *TranscodingCodec) Marshal(v any) ([]byte, error) {
+	f, ok := v.(*TranscodingFrame)
+	if !ok {
+		return nil, fmt.Errorf("transcoding: expected *TranscodingFrame, got %T", v)
+	}
+
+	desc, err := c.messageDescriptor(f.FullMethod, f.Direction)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(f.Payload, msg); err != nil {
+		return nil, fmt.Errorf("transcoding: decode upstream payload for %q: %w", f.FullMethod, err)
+	}
+
+	return protojson.Marshal(msg)
+}
+
+func (c *TranscodingCodec) Unmarshal(data []byte, v any) error {
+	f, ok := v.(*TranscodingFrame)
+	if !ok {
+		return fmt.Errorf("transcoding: expected *TranscodingFrame, got %T", v)
+	}
+
+	desc, err := c.messageDescriptor(f.FullMethod, f.Direction)
+	if err != nil {
+		return err
+	}
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("transcoding: decode JSON payload for %q: %w", f.FullMethod, err)
+	}
+
+	wire, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("transcoding: re-encode payload for %q: %w", f.FullMethod, err)
+	}
+
+	f.Payload = wire
+	return nil
+}
+
+// messageDescriptor 解析 fullMethod（形如 /pkg.Service/Method）对应的 input 或 output MessageDescriptor。
+func (c *TranscodingCodec) messageDescriptor(fullMethod string, dir Direction) (protoreflect.MessageDescriptor, error) {
+	serviceName, methodName, err := splitFullMethod(fullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	svcDesc, err := findServiceDescriptor(c.files, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	methodDesc := svcDesc.Methods().ByName(protoreflect.Name(methodName))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("transcoding: method %q not found on service %q", methodName, serviceName)
+	}
+
+	if dir == DirectionRequest {
+		return methodDesc.Input(), nil
+	}
+
+	return methodDesc.Output(), nil
+}
+
+// splitFullMethod 把 "/pkg.Service/Method" 拆分为 service 全名与 method 名。
+func splitFullMethod(fullMethod string) (service, method string, err error) {
+	trimmed := strings.TrimPrefix(fullMethod, "/")
+	idx := strings.LastIndex(trimmed, "/")
+	if idx < 0 {
+		return "", "", fmt.Errorf("transcoding: malformed full method name %q", fullMethod)
+	}
+
+	return trimmed[:idx], trimmed[idx+1:], nil
+}
+
+// findServiceDescriptor 在 files 中按全名查找 ServiceDescriptor。
+func findServiceDescriptor(files *protoregistry.Files, serviceName string) (protoreflect.ServiceDescriptor, error) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("transcoding: service %q not found in descriptor set: %w", serviceName, err)
+	}
+
+	svcDesc, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("transcoding: %q is not a service", serviceName)
+	}
+
+	return svcDesc, nil
+}
@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewTranscodingHandler 提供与 TransparentHandler 等价的转发方式，区别在于入站/出站帧类型：
+// - 入站侧（server stream）使用 TranscodingCodec，按 JSON 收发
+// - 出站侧（client stream）固定使用 RawProtoCodec，对上游保持原始 protobuf bytes
+// 两者之间按 Payload 互转，上游完全感知不到转码的存在。
+// interceptors 语义与 TransparentHandler 一致：按顺序串联执行，作用在互转后得到的 *RawProtoFrame 上。
+func NewTranscodingHandler(director StreamDirector, interceptors ...ProxyInterceptor) grpc.StreamHandler {
+	streamer := &transcodingHandler{director: director, interceptor: ChainInterceptors(interceptors...)}
+
+	return streamer.Handler
+}
+
+type transcodingHandler struct {
+	// director 根据 fullMethodName 选择目标连接，语义与 Handler.director 一致。
+	director StreamDirector
+	// interceptor 是配置给该 handler 的拦截器（可能是 InterceptorChain），nil 视为空操作。
+	interceptor ProxyInterceptor
+}
+
+func (h *transcodingHandler) Handler(srv interface{}, serverStream grpc.ServerStream) (err error) {
+	fullMethodName, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return status.Errorf(codes.Internal, "lowLevelServerStream does not exist in context")
+	}
+
+	interceptor := orNoopInterceptor(h.interceptor)
+
+	// defer 必须在调用 OnStreamStart 之前注册，否则被拦截器短路拒绝的调用永远不会触发 OnFinish，
+	// 详见 Handler.Handler 同一处的注释。
+	ctx := serverStream.Context()
+	var trailer metadata.MD
+	defer func() {
+		interceptor.OnFinish(ctx, err, trailer)
+	}()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, err = interceptor.OnStreamStart(ctx, fullMethodName, md)
+	if err != nil {
+		return err
+	}
+	serverStream = &contextServerStream{ServerStream: serverStream, ctx: ctx}
+
+	outgoingCtx, targetConn, err := h.director(ctx, fullMethodName)
+	if err != nil {
+		return err
+	}
+	if targetConn == nil {
+		return status.Errorf(codes.Unavailable, "target connection is nil")
+	}
+
+	if injector, ok := interceptor.(outgoingMetadataInjector); ok {
+		outMD, _ := metadata.FromOutgoingContext(outgoingCtx)
+		outMD = outMD.Copy()
+		injector.InjectOutgoingTraceContext(outgoingCtx, outMD)
+		outgoingCtx = metadata.NewOutgoingContext(outgoingCtx, outMD)
+	}
+
+	clientCtx, clientCancel := context.WithCancel(outgoingCtx)
+	defer clientCancel()
+
+	clientStream, err := grpc.NewClientStream(clientCtx, clientStreamDescForProxying, targetConn, fullMethodName, DefaultClientCallOpts()...)
+	if err != nil {
+		return err
+	}
+
+	inboundToOutboundErrChan := h.forwardInboundToOutbound(ctx, fullMethodName, serverStream, clientStream, interceptor)
+	outboundToInboundErrChan := h.forwardOutboundToInbound(ctx, fullMethodName, clientStream, serverStream, interceptor)
+
+	// 复用 Handler.Handler 同款的 streamCoordinator，取代这里原先手写、对 ctx 取消无感的两轮 select：
+	// 客户端中途取消时需要立即结束，而不是一直等待两个转发 goroutine 自然返回。
+	coordinator := &streamCoordinator{ctx: ctx, serverStream: serverStream, clientStream: clientStream, clientCancel: clientCancel}
+	trailer, err = coordinator.run(inboundToOutboundErrChan, outboundToInboundErrChan)
+
+	return err
+}
+
+// forwardInboundToOutbound 把入站 JSON 消息（已由 TranscodingCodec 转为 protobuf wire bytes）转发到出站 RawProtoCodec 流，
+// 转发前按 OnClientMsg 让拦截器处理互转后得到的 *RawProtoFrame。
+func (h *transcodingHandler) forwardInboundToOutbound(ctx context.Context, fullMethod string, src grpc.ServerStream, dst grpc.ClientStream, interceptor ProxyInterceptor) chan error {
+	ret := make(chan error, 1)
+
+	go func() {
+		for {
+			// tf 由 server 侧的 TranscodingCodec 填充：Unmarshal 把收到的 JSON 解析为 input message 再编码成 Payload。
+			tf := &TranscodingFrame{FullMethod: fullMethod, Direction: DirectionRequest}
+			if err := src.RecvMsg(tf); err != nil {
+				ret <- err
+				break
+			}
+
+			rf := &RawProtoFrame{Payload: tf.Payload}
+			if err := interceptor.OnClientMsg(ctx, rf); err != nil {
+				ret <- err
+				break
+			}
+
+			if err := dst.SendMsg(rf); err != nil {
+				ret <- err
+				break
+			}
+		}
+	}()
+
+	return ret
+}
+
+// forwardOutboundToInbound 把出站 protobuf 响应转发回入站 JSON 流，由 TranscodingCodec 在 SendMsg 时转码为 JSON，
+// 转发前按 OnHeader/OnServerMsg 让拦截器处理上游 header 与互转后得到的 *RawProtoFrame。
+func (h *transcodingHandler) forwardOutboundToInbound(ctx context.Context, fullMethod string, src grpc.ClientStream, dst grpc.ServerStream, interceptor ProxyInterceptor) chan error {
+	ret := make(chan error, 1)
+
+	go func() {
+		for i := 0; ; i++ {
+			rf := &RawProtoFrame{}
+			if err := src.RecvMsg(rf); err != nil {
+				ret <- err
+				break
+			}
+
+			if i == 0 {
+				md, err := src.Header()
+				if err != nil {
+					ret <- err
+					break
+				}
+
+				interceptor.OnHeader(ctx, md)
+
+				if err := dst.SendHeader(md); err != nil {
+					ret <- err
+					break
+				}
+			}
+
+			if err := interceptor.OnServerMsg(ctx, rf); err != nil {
+				ret <- err
+				break
+			}
+
+			// tf 交由入站 TranscodingCodec 的 Marshal 按 output message 转码为 JSON bytes。
+			tf := &TranscodingFrame{FullMethod: fullMethod, Direction: DirectionResponse, Payload: rf.Payload}
+			if err := dst.SendMsg(tf); err != nil {
+				ret <- err
+				break
+			}
+		}
+	}()
+
+	return ret
+}
@@ -0,0 +1,312 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// buildDemoFileDescriptorSet 手写构造一份最小的 FileDescriptorSet（不依赖 protoc），
+// 描述 acme.demo.v1.DemoService：Echo 是一元方法，Stream 是 server-streaming 方法，
+// 两者的 input/output 都是只有一个 text 字段的 EchoMessage。
+func buildDemoFileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	textField := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("text"),
+		Number:   proto.Int32(1),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		JsonName: proto.String("text"),
+	}
+
+	echoMessage := &descriptorpb.DescriptorProto{
+		Name:  proto.String("EchoMessage"),
+		Field: []*descriptorpb.FieldDescriptorProto{textField},
+	}
+
+	demoService := &descriptorpb.ServiceDescriptorProto{
+		Name: proto.String("DemoService"),
+		Method: []*descriptorpb.MethodDescriptorProto{
+			{
+				Name:       proto.String("Echo"),
+				InputType:  proto.String(".acme.demo.v1.EchoMessage"),
+				OutputType: proto.String(".acme.demo.v1.EchoMessage"),
+			},
+			{
+				Name:            proto.String("Stream"),
+				InputType:       proto.String(".acme.demo.v1.EchoMessage"),
+				OutputType:      proto.String(".acme.demo.v1.EchoMessage"),
+				ServerStreaming: proto.Bool(true),
+			},
+		},
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("acme/demo/v1/demo.proto"),
+		Package:     proto.String("acme.demo.v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{echoMessage},
+		Service:     []*descriptorpb.ServiceDescriptorProto{demoService},
+	}
+
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}}
+}
+
+// startRawEchoServer 启动一个只注册 Echo 方法、原样回显 RawProtoFrame.Payload 的上游 server，
+// 供 HTTPGateway 的 unary 转码测试拨号。
+func startRawEchoServer(t *testing.T) *bufconn.Listener {
+	t.Helper()
+
+	lis := bufconn.Listen(bufConnSize)
+	srv := grpc.NewServer(grpc.ForceServerCodec(RawProtoCodec{}))
+
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "acme.demo.v1.DemoService",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName: "Echo",
+				Handler: func(srv any, stream grpc.ServerStream) error {
+					req := &RawProtoFrame{}
+					if err := stream.RecvMsg(req); err != nil {
+						return err
+					}
+
+					return stream.SendMsg(&RawProtoFrame{Payload: req.Payload})
+				},
+				ServerStreams: true,
+				ClientStreams: true,
+			},
+		},
+	}, nil)
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	return lis
+}
+
+func TestTranscodingCodec_RoundTrip(t *testing.T) {
+	codec, err := NewTranscodingCodec(buildDemoFileDescriptorSet())
+	if err != nil {
+		t.Fatalf("new codec: %v", err)
+	}
+
+	// Unmarshal：JSON -> TranscodingFrame.Payload（原始 protobuf wire bytes）。
+	reqFrame := &TranscodingFrame{FullMethod: "/acme.demo.v1.DemoService/Echo", Direction: DirectionRequest}
+	if err := codec.Unmarshal([]byte(`{"text":"ping"}`), reqFrame); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(reqFrame.Payload) == 0 {
+		t.Fatalf("expected non-empty wire payload")
+	}
+
+	// Marshal：把同一份 wire bytes（当作 output type）转回 JSON，验证往返一致。
+	respFrame := &TranscodingFrame{FullMethod: "/acme.demo.v1.DemoService/Echo", Direction: DirectionResponse, Payload: reqFrame.Payload}
+	respJSON, err := codec.Marshal(respFrame)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var got struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respJSON, &got); err != nil {
+		t.Fatalf("decode response JSON: %v", err)
+	}
+	if got.Text != "ping" {
+		t.Fatalf("round trip mismatch: got %q want %q", got.Text, "ping")
+	}
+}
+
+func TestHTTPGateway_UnaryRoundTrip(t *testing.T) {
+	targetLis := startRawEchoServer(t)
+
+	ctx := context.Background()
+	conn, err := dialBufConn(ctx, targetLis)
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	gateway, err := NewHTTPGateway(conn, buildDemoFileDescriptorSet())
+	if err != nil {
+		t.Fatalf("new gateway: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/acme.demo.v1.DemoService/Echo", strings.NewReader(`{"text":"ping"}`))
+	rec := httptest.NewRecorder()
+	gateway.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	var got struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response JSON: %v", err)
+	}
+	if got.Text != "ping" {
+		t.Fatalf("round trip mismatch: got %q want %q", got.Text, "ping")
+	}
+}
+
+func TestHTTPGateway_RejectsStreamingMethod(t *testing.T) {
+	targetLis := startRawEchoServer(t)
+
+	ctx := context.Background()
+	conn, err := dialBufConn(ctx, targetLis)
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	gateway, err := NewHTTPGateway(conn, buildDemoFileDescriptorSet())
+	if err != nil {
+		t.Fatalf("new gateway: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/acme.demo.v1.DemoService/Stream", strings.NewReader(`{"text":"ping"}`))
+	rec := httptest.NewRecorder()
+	gateway.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected %d for a streaming method, got %d body=%s", http.StatusNotImplemented, rec.Code, rec.Body.String())
+	}
+}
+
+// startBlockingStreamServer 启动一个只注册 Stream 方法的上游，收到第一条消息后一直阻塞到
+// stream 的 context 被取消为止，模拟“客户端已取消，但上游还不知道”的场景。
+func startBlockingStreamServer(t *testing.T) *bufconn.Listener {
+	t.Helper()
+
+	lis := bufconn.Listen(bufConnSize)
+	srv := grpc.NewServer(grpc.ForceServerCodec(RawProtoCodec{}))
+
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "acme.demo.v1.DemoService",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName: "Stream",
+				Handler: func(srv any, stream grpc.ServerStream) error {
+					req := &RawProtoFrame{}
+					if err := stream.RecvMsg(req); err != nil {
+						return err
+					}
+
+					<-stream.Context().Done()
+					return stream.Context().Err()
+				},
+				ServerStreams: true,
+				ClientStreams: true,
+			},
+		},
+	}, nil)
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	return lis
+}
+
+// TestTranscodingHandler_ClientCancelMidStream 验证 transcodingHandler 在客户端中途取消调用时，
+// 能及时退出而不是一直阻塞在 RecvMsg 上——与 TestHandler_ClientCancelMidStream 覆盖的是同一个
+// 场景，只是走的是 JSON 转码这条路径（transcodingHandler.Handler 内部复用的 streamCoordinator）。
+func TestTranscodingHandler_ClientCancelMidStream(t *testing.T) {
+	targetLis := startBlockingStreamServer(t)
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(dialCancel)
+
+	targetConn, err := dialBufConn(dialCtx, targetLis)
+	if err != nil {
+		t.Fatalf("dial target: %v", err)
+	}
+	t.Cleanup(func() { _ = targetConn.Close() })
+
+	gatewaySrv, err := NewJSONProxy(targetConn, buildDemoFileDescriptorSet(), nil)
+	if err != nil {
+		t.Fatalf("new json proxy: %v", err)
+	}
+
+	gatewayLis := bufconn.Listen(bufConnSize)
+	go func() {
+		_ = gatewaySrv.Serve(gatewayLis)
+	}()
+	t.Cleanup(func() {
+		gatewaySrv.Stop()
+		_ = gatewayLis.Close()
+	})
+
+	gatewayConn, err := dialBufConn(dialCtx, gatewayLis)
+	if err != nil {
+		t.Fatalf("dial gateway: %v", err)
+	}
+	t.Cleanup(func() { _ = gatewayConn.Close() })
+
+	codec, err := NewTranscodingCodec(buildDemoFileDescriptorSet())
+	if err != nil {
+		t.Fatalf("new codec: %v", err)
+	}
+
+	// callCtx 独立于 dialCtx，以便在调用中途单独取消它。
+	callCtx, callCancel := context.WithCancel(context.Background())
+
+	streamDesc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	stream, err := grpc.NewClientStream(callCtx, streamDesc, gatewayConn, "/acme.demo.v1.DemoService/Stream", grpc.ForceCodec(codec))
+	if err != nil {
+		t.Fatalf("new client stream: %v", err)
+	}
+
+	// reqFrame.Payload 需要预先填充为 wire bytes：SendMsg 经 ForceCodec 指定的 codec.Marshal
+	// 把 Payload 转成 JSON 发到 wire 上，因此这里借 codec.Unmarshal 从 JSON 反向生成它。
+	reqFrame := &TranscodingFrame{FullMethod: "/acme.demo.v1.DemoService/Stream", Direction: DirectionRequest}
+	if err := codec.Unmarshal([]byte(`{"text":"ping"}`), reqFrame); err != nil {
+		t.Fatalf("build request payload: %v", err)
+	}
+	if err := stream.SendMsg(reqFrame); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// 取消调用而不等待响应，模拟客户端中途放弃。
+	callCancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.RecvMsg(&TranscodingFrame{FullMethod: "/acme.demo.v1.DemoService/Stream", Direction: DirectionResponse})
+	}()
+
+	select {
+	case recvErr := <-done:
+		if status.Code(recvErr) != codes.Canceled {
+			t.Fatalf("expected Canceled, got: %v (%v)", recvErr, status.Code(recvErr))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RecvMsg did not return after client cancellation: possible goroutine leak")
+	}
+}